@@ -0,0 +1,263 @@
+package accesslog
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headers carrying the request IDs, as in S3
+const (
+	RequestIDHeader = "x-amz-request-id"
+	HostIDHeader    = "x-amz-id-2"
+)
+
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+// SetLogger changes where access lines are written, nil turns them off.
+// It is meant to be called before the server starts
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+// Entry describes one request; handlers deeper in the chain fill in what
+// only they know through the pointer on the request context
+type Entry struct {
+	RequestID  string
+	HostID     string
+	Time       time.Time
+	RemoteIP   string
+	Method     string
+	Bucket     string
+	Key        string
+	Operation  string
+	RequestURI string
+	Proto      string
+	Host       string
+	Referer    string
+	AuthType   string
+	TLSVersion string
+	Caller     string
+	Status     int
+	ErrorCode  string
+	BytesIn    int64
+	BytesOut   int64
+	Latency    time.Duration
+	UserAgent  string
+}
+
+var (
+	listenersMu sync.Mutex
+	listeners   []func(*Entry)
+)
+
+// Listen registers fn to be called with the entry of every answered request;
+// fn runs on the request's goroutine, so it must be quick
+func Listen(fn func(*Entry)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+type entryKey struct{}
+
+// FromContext returns the entry of the request ctx belongs to, or nil
+func FromContext(ctx context.Context) *Entry {
+	entry, _ := ctx.Value(entryKey{}).(*Entry)
+	return entry
+}
+
+// SetCaller records the authenticated user of the request
+func SetCaller(ctx context.Context, user string) {
+	if entry := FromContext(ctx); entry != nil {
+		entry.Caller = user
+	}
+}
+
+// Middleware assigns request IDs, returns them in the response headers and
+// writes one JSON access log line once the request is answered
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &Entry{
+			RequestID:  newRequestID(),
+			HostID:     newHostID(),
+			Time:       time.Now(),
+			RemoteIP:   remoteIP(r),
+			Method:     r.Method,
+			RequestURI: redactedURI(r),
+			Proto:      r.Proto,
+			Host:       r.Host,
+			Referer:    r.Referer(),
+			AuthType:   authType(r),
+			UserAgent:  r.UserAgent(),
+		}
+		entry.Bucket, entry.Key, _ = strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		entry.Operation = operation(r, entry.Key)
+		if r.TLS != nil {
+			entry.TLSVersion = tls.VersionName(r.TLS.Version)
+		}
+
+		w.Header().Set(RequestIDHeader, entry.RequestID)
+		w.Header().Set(HostIDHeader, entry.HostID)
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), entryKey{}, entry)))
+
+		entry.Status = recorder.status
+		entry.BytesIn = body.n
+		entry.BytesOut = recorder.n
+		entry.Latency = time.Since(entry.Time)
+		write(entry)
+
+		listenersMu.Lock()
+		current := listeners
+		listenersMu.Unlock()
+		for _, fn := range current {
+			fn(entry)
+		}
+	})
+}
+
+// subresources name the operation of requests carrying them, in the order
+// they are dispatched
+var subresources = []string{
+	"policy", "acl", "cors", "website", "encryption", "compression", "notification",
+	"events", "replication", "object-lock", "retention", "legal-hold", "logging",
+}
+
+// operation is the S3 name of the request, such as REST.GET.OBJECT or REST.PUT.ACL
+func operation(r *http.Request, key string) string {
+	resource := "BUCKET"
+	if key != "" {
+		resource = "OBJECT"
+	}
+	if strings.HasPrefix(r.URL.Path, "/_admin/") {
+		resource = "ADMIN"
+	}
+	query := r.URL.Query()
+	for _, sub := range subresources {
+		if query.Has(sub) {
+			resource = strings.ToUpper(strings.ReplaceAll(sub, "-", "_"))
+			break
+		}
+	}
+	if r.Method == http.MethodPut && r.Header.Get("x-amz-copy-source") != "" {
+		resource = "COPY"
+	}
+	return "REST." + r.Method + "." + resource
+}
+
+// redactedURI is the request URI without presigned URL signatures, which
+// would let anyone reading the log repeat the request
+func redactedURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has("X-Amz-Signature") {
+		return r.URL.RequestURI()
+	}
+	query.Set("X-Amz-Signature", "REDACTED")
+	return r.URL.EscapedPath() + "?" + query.Encode()
+}
+
+func authType(r *http.Request) string {
+	switch {
+	case r.URL.Query().Has("X-Amz-Signature"):
+		return "QueryString"
+	case r.Header.Get("Authorization") != "":
+		return "AuthHeader"
+	}
+	return ""
+}
+
+func write(entry *Entry) {
+	if logger == nil {
+		return
+	}
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "access",
+		slog.String("request_id", entry.RequestID),
+		slog.String("host_id", entry.HostID),
+		slog.String("remote_ip", entry.RemoteIP),
+		slog.String("caller", entry.Caller),
+		slog.String("method", entry.Method),
+		slog.String("bucket", entry.Bucket),
+		slog.String("key", entry.Key),
+		slog.String("operation", entry.Operation),
+		slog.Int("status", entry.Status),
+		slog.String("error_code", entry.ErrorCode),
+		slog.Int64("bytes_in", entry.BytesIn),
+		slog.Int64("bytes_out", entry.BytesOut),
+		slog.Float64("latency_ms", float64(entry.Latency.Microseconds())/1000),
+		slog.String("user_agent", entry.UserAgent),
+	)
+}
+
+// newRequestID returns 16 upper-case hex characters like S3's request IDs
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return strings.ToUpper(hex.EncodeToString(id))
+}
+
+func newHostID() string {
+	id := make([]byte, 32)
+	rand.Read(id)
+	return base64.StdEncoding.EncodeToString(id)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	n           int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach Flush for event streams
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,84 @@
+package acl
+
+import (
+	"A3S/internal/models"
+)
+
+// Canned ACLs accepted in the x-amz-acl header
+const (
+	Private           = "private"
+	PublicRead        = "public-read"
+	PublicReadWrite   = "public-read-write"
+	AuthenticatedRead = "authenticated-read"
+)
+
+const (
+	allUsersURI           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUsersURI = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	xsiNamespace          = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// readActions are the actions a READ grant covers
+var readActions = map[string]bool{
+	"s3:GetObject":  true,
+	"s3:ListBucket": true,
+}
+
+// writeActions are the actions a WRITE grant on the bucket covers
+var writeActions = map[string]bool{
+	"s3:PutObject":    true,
+	"s3:DeleteObject": true,
+}
+
+func Valid(canned string) bool {
+	switch canned {
+	case Private, PublicRead, PublicReadWrite, AuthenticatedRead:
+		return true
+	}
+	return false
+}
+
+// IsWrite reports whether action is governed by the bucket's ACL rather than the object's
+func IsWrite(action string) bool {
+	return writeActions[action]
+}
+
+// Grants reports whether a canned ACL lets a non-owner caller perform action;
+// caller is "" for anonymous requests
+func Grants(canned, caller, action string) bool {
+	switch canned {
+	case PublicReadWrite:
+		return readActions[action] || writeActions[action]
+	case PublicRead:
+		return readActions[action]
+	case AuthenticatedRead:
+		return caller != "" && readActions[action]
+	}
+	return false
+}
+
+// Policy expands a canned ACL into the grant list S3 returns from ?acl
+func Policy(canned, owner string) models.AccessControlPolicy {
+	ownerGrantee := models.Grantee{XMLNS: xsiNamespace, Type: "CanonicalUser", ID: owner, DisplayName: owner}
+	policy := models.AccessControlPolicy{
+		Owner: models.Owner{ID: owner, DisplayName: owner},
+		AccessControlList: []models.Grant{
+			{Grantee: ownerGrantee, Permission: "FULL_CONTROL"},
+		},
+	}
+
+	group := func(uri, permission string) models.Grant {
+		return models.Grant{Grantee: models.Grantee{XMLNS: xsiNamespace, Type: "Group", URI: uri}, Permission: permission}
+	}
+
+	switch canned {
+	case PublicRead:
+		policy.AccessControlList = append(policy.AccessControlList, group(allUsersURI, "READ"))
+	case PublicReadWrite:
+		policy.AccessControlList = append(policy.AccessControlList,
+			group(allUsersURI, "READ"), group(allUsersURI, "WRITE"))
+	case AuthenticatedRead:
+		policy.AccessControlList = append(policy.AccessControlList, group(authenticatedUsersURI, "READ"))
+	}
+	return policy
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"A3S/internal/accesslog"
+	"A3S/internal/iam"
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"A3S/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RootUser is the identity behind the --access-key/--secret-key pair
+const RootUser = "root"
+
+type callerKey struct{}
+
+type accessKeyKey struct{}
+
+// SecretFor returns the secret key paired with accessKey and the user owning it.
+// The root pair from the command line takes precedence over the identity store.
+func SecretFor(s *models.Storage, accessKey string) (string, string, bool) {
+	if *utils.AccessKey != "" && accessKey == *utils.AccessKey {
+		return *utils.SecretKey, RootUser, true
+	}
+	return iam.Authenticate(s, accessKey)
+}
+
+// Middleware verifies SigV4 signatures (header or presigned query), or else a
+// verified TLS client certificate, and stores the caller on the request
+// context. Other requests pass through as anonymous.
+func Middleware(s *models.Storage, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user string
+		var err error
+
+		// the code a failure is answered with when it is no signature error
+		malformed := s3err.AuthorizationHeaderMalformed
+		switch {
+		case IsPresigned(r):
+			malformed = s3err.AuthorizationQueryParametersError
+			user, err = VerifyPresigned(r, s, time.Now())
+		case r.Header.Get("Authorization") != "":
+			user, err = VerifyHeader(r, s, time.Now())
+		case r.TLS != nil && len(r.TLS.VerifiedChains) > 0:
+			malformed = s3err.AccessDenied
+			user, err = certificateUser(r, s)
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err != nil {
+			log.Printf("Rejected signed request %s %s: %v", r.Method, r.URL.Path, err)
+			switch {
+			case errors.Is(err, ErrUnknownAccessKey):
+				s3err.Write(w, r, s3err.InvalidAccessKeyID, "")
+			case errors.Is(err, ErrSignatureInvalid):
+				s3err.Write(w, r, s3err.SignatureDoesNotMatch, "")
+			case errors.Is(err, ErrExpired):
+				s3err.Write(w, r, s3err.ExpiredToken, "")
+			case errors.Is(err, ErrNotYetValid):
+				s3err.Write(w, r, s3err.AccessDenied, "Request is not valid yet")
+			case errors.Is(err, ErrTimeSkewed):
+				s3err.Write(w, r, s3err.RequestTimeTooSkewed, "")
+			default:
+				s3err.Write(w, r, malformed, err.Error())
+			}
+			return
+		}
+
+		accesslog.SetCaller(r.Context(), user)
+		ctx := context.WithValue(r.Context(), callerKey{}, user)
+		ctx = context.WithValue(ctx, accessKeyKey{}, signingKey(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// certificateUser maps a verified client certificate to the IAM user named by
+// its common name; root is never reachable this way, only through its keys
+func certificateUser(r *http.Request, s *models.Storage) (string, error) {
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if name == RootUser {
+		return "", errors.New("client certificates cannot authenticate as root")
+	}
+	user := iam.FindUser(s, name)
+	if user == nil || user.Status != iam.StatusActive {
+		return "", fmt.Errorf("client certificate for '%s' does not match an active user", name)
+	}
+	return user.Name, nil
+}
+
+// Caller returns the authenticated user name, or "" for anonymous requests
+func Caller(r *http.Request) string {
+	user, _ := r.Context().Value(callerKey{}).(string)
+	return user
+}
+
+// AccessKey returns the access key the request was signed with, or "" for
+// anonymous requests and those authenticated by a client certificate
+func AccessKey(r *http.Request) string {
+	key, _ := r.Context().Value(accessKeyKey{}).(string)
+	return key
+}
+
+// signingKey reads the access key out of the credential of a signed request
+func signingKey(r *http.Request) string {
+	var credential string
+	switch {
+	case IsPresigned(r):
+		credential = r.URL.Query().Get("X-Amz-Credential")
+	case r.Header.Get("Authorization") != "":
+		credential = authorizationFields(r.Header.Get("Authorization"))["Credential"]
+	}
+	key, _, _ := strings.Cut(credential, "/")
+	return key
+}
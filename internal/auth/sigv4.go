@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"A3S/internal/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Algorithm       = "AWS4-HMAC-SHA256"
+	Region          = "us-east-1"
+	Service         = "s3"
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	amzDateFormat   = "20060102T150405Z"
+	shortDateFormat = "20060102"
+
+	// S3 refuses presigned URLs valid for longer than seven days
+	MaxPresignExpiry = 7 * 24 * time.Hour
+
+	// header-signed requests must be dated this close to the server clock
+	MaxClockSkew = 15 * time.Minute
+)
+
+var (
+	ErrExpired          = errors.New("request has expired")
+	ErrNotYetValid      = errors.New("request is not valid yet")
+	ErrSignatureInvalid = errors.New("the request signature we calculated does not match the signature you provided")
+	ErrUnknownAccessKey = errors.New("the access key id you provided does not exist in our records")
+	ErrTimeSkewed       = errors.New("the difference between the request time and the current time is too large")
+	ErrPayloadMismatch  = errors.New("the provided x-amz-content-sha256 header does not match what was computed")
+)
+
+// PresignURL builds a query-signed URL for method on /bucket/key that is valid for expires from now
+func PresignURL(method, endpoint, bucket, key, accessKey, secretKey string, expires time.Duration, now time.Time) (string, error) {
+	if expires <= 0 || expires > MaxPresignExpiry {
+		return "", fmt.Errorf("expiry must be between 1s and %s", MaxPresignExpiry)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	u.Path = "/" + bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+
+	now = now.UTC()
+	scope := credentialScope(now)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", Algorithm)
+	query.Set("X-Amz-Credential", accessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := strings.Join([]string{
+		strings.ToUpper(method),
+		canonicalURI(u.Path),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		UnsignedPayload,
+	}, "\n")
+
+	signature := sign(secretKey, now, stringToSign(now, scope, canonical))
+	query.Set("X-Amz-Signature", signature)
+
+	u.RawQuery = canonicalQuery(query)
+	return u.String(), nil
+}
+
+// SignRequest adds an Authorization header to an outgoing request. The body
+// is not hashed, the request is sent with an UNSIGNED-PAYLOAD content hash.
+func SignRequest(r *http.Request, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	scope := credentialScope(now)
+
+	r.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	r.Header.Set("X-Amz-Content-Sha256", UnsignedPayload)
+	if r.Host == "" {
+		r.Host = r.URL.Host
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, signedHeaders),
+		signedHeaders,
+		UnsignedPayload,
+	}, "\n")
+
+	signature := sign(secretKey, now, stringToSign(now, scope, canonical))
+	r.Header.Set("Authorization", Algorithm+" Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// IsPresigned reports whether the request carries query string authentication
+func IsPresigned(r *http.Request) bool {
+	return r.URL.Query().Get("X-Amz-Algorithm") != ""
+}
+
+// VerifyPresigned checks the signature and expiry of a query-signed request
+// and returns the user that signed it
+func VerifyPresigned(r *http.Request, s *models.Storage, now time.Time) (string, error) {
+	query := r.URL.Query()
+
+	if query.Get("X-Amz-Algorithm") != Algorithm {
+		return "", fmt.Errorf("unsupported algorithm %q", query.Get("X-Amz-Algorithm"))
+	}
+
+	accessKey, date, err := parseCredential(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return "", err
+	}
+
+	signedAt, err := time.Parse(amzDateFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return "", errors.New("X-Amz-Date must be in the ISO8601 basic format")
+	}
+	if signedAt.Format(shortDateFormat) != date {
+		return "", errors.New("credential scope date does not match X-Amz-Date")
+	}
+
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > MaxPresignExpiry {
+		return "", errors.New("X-Amz-Expires must be between 1 and 604800 seconds")
+	}
+	if now.After(signedAt.Add(time.Duration(seconds) * time.Second)) {
+		return "", ErrExpired
+	}
+	// a date ahead of the clock would stretch the URL past MaxPresignExpiry
+	if signedAt.Sub(now) > MaxClockSkew {
+		return "", ErrNotYetValid
+	}
+
+	secretKey, user, ok := SecretFor(s, accessKey)
+	if !ok {
+		return "", ErrUnknownAccessKey
+	}
+
+	// without host the URL could be sent to any server sharing the key
+	signedHeaders := query.Get("X-Amz-SignedHeaders")
+	if !slices.Contains(strings.Split(signedHeaders, ";"), "host") {
+		return "", errors.New("X-Amz-SignedHeaders must include host")
+	}
+	provided := query.Get("X-Amz-Signature")
+	query.Del("X-Amz-Signature")
+
+	canonical := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(query),
+		canonicalHeaders(r, signedHeaders),
+		signedHeaders,
+		UnsignedPayload,
+	}, "\n")
+
+	scope := credentialScope(signedAt)
+	expected := sign(secretKey, signedAt, stringToSign(signedAt, scope, canonical))
+	if !hmac.Equal([]byte(expected), []byte(provided)) {
+		return "", ErrSignatureInvalid
+	}
+
+	return user, nil
+}
+
+// VerifyHeader checks an Authorization header signed with AWS4-HMAC-SHA256
+// and returns the user that signed it. A body with a signed content hash is
+// checked as it is read, reads fail with ErrPayloadMismatch when it differs.
+func VerifyHeader(r *http.Request, s *models.Storage, now time.Time) (string, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, Algorithm+" ") {
+		return "", errors.New("unsupported authorization type")
+	}
+
+	fields := authorizationFields(header)
+
+	accessKey, date, err := parseCredential(fields["Credential"])
+	if err != nil {
+		return "", err
+	}
+
+	signedAt, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return "", errors.New("X-Amz-Date header must be in the ISO8601 basic format")
+	}
+	if signedAt.Format(shortDateFormat) != date {
+		return "", errors.New("credential scope date does not match X-Amz-Date")
+	}
+	// a captured request can only be replayed within the skew window
+	if now.Sub(signedAt).Abs() > MaxClockSkew {
+		return "", ErrTimeSkewed
+	}
+
+	// the content hash has to be signed, or the body could be swapped
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !slices.Contains(signed, "host") || !slices.Contains(signed, "x-amz-content-sha256") {
+		return "", errors.New("SignedHeaders must include host and x-amz-content-sha256")
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != UnsignedPayload && !isSHA256(payloadHash) {
+		return "", fmt.Errorf("unsupported x-amz-content-sha256 %q", payloadHash)
+	}
+
+	secretKey, user, ok := SecretFor(s, accessKey)
+	if !ok {
+		return "", ErrUnknownAccessKey
+	}
+
+	canonical := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, fields["SignedHeaders"]),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+
+	scope := credentialScope(signedAt)
+	expected := sign(secretKey, signedAt, stringToSign(signedAt, scope, canonical))
+	if !hmac.Equal([]byte(expected), []byte(fields["Signature"])) {
+		return "", ErrSignatureInvalid
+	}
+
+	if payloadHash != UnsignedPayload {
+		if r.Body == nil {
+			r.Body = http.NoBody
+		}
+		r.Body = &payloadVerifier{ReadCloser: r.Body, hash: sha256.New(), want: payloadHash}
+	}
+	return user, nil
+}
+
+func isSHA256(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size && s == strings.ToLower(s)
+}
+
+// payloadVerifier hashes a request body as it is read and fails the read
+// that reaches the end when the hash is not the signed one
+type payloadVerifier struct {
+	io.ReadCloser
+	hash hash.Hash
+	want string
+}
+
+func (p *payloadVerifier) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	p.hash.Write(b[:n])
+	if err == io.EOF && hex.EncodeToString(p.hash.Sum(nil)) != p.want {
+		return n, ErrPayloadMismatch
+	}
+	return n, err
+}
+
+// authorizationFields splits the Credential, SignedHeaders and Signature
+// fields out of an Authorization header
+func authorizationFields(header string) map[string]string {
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, Algorithm+" "), ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// parseCredential splits "AKID/20240101/us-east-1/s3/aws4_request"
+func parseCredential(credential string) (string, string, error) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] == "" {
+		return "", "", errors.New("malformed credential")
+	}
+	if parts[2] != Region || parts[3] != Service || parts[4] != "aws4_request" {
+		return "", "", errors.New("credential scope must be <date>/" + Region + "/" + Service + "/aws4_request")
+	}
+	return parts[0], parts[1], nil
+}
+
+func credentialScope(t time.Time) string {
+	return t.Format(shortDateFormat) + "/" + Region + "/" + Service + "/aws4_request"
+}
+
+func stringToSign(t time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return Algorithm + "\n" + t.Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+}
+
+func sign(secretKey string, t time.Time, toSign string) string {
+	key := hmacSHA256([]byte("AWS4"+secretKey), t.Format(shortDateFormat))
+	key = hmacSHA256(key, Region)
+	key = hmacSHA256(key, Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(r *http.Request, signedHeaders string) string {
+	var b strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else {
+			value = strings.Join(r.Header.Values(name), ",")
+		}
+		b.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	return b.String()
+}
+
+// uriEncode follows the AWS rules: everything but unreserved characters is
+// percent-encoded, and '/' is kept as-is inside object paths
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
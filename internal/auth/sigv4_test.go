@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useRootKey(t *testing.T) {
+	t.Helper()
+	accessKey, secretKey := *utils.AccessKey, *utils.SecretKey
+	*utils.AccessKey, *utils.SecretKey = "AKROOT", "root-secret"
+	t.Cleanup(func() { *utils.AccessKey, *utils.SecretKey = accessKey, secretKey })
+}
+
+func TestVerifyPresigned(t *testing.T) {
+	useRootKey(t)
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		now    time.Time
+		edit   func(u *url.URL)
+		err    error // nil with msg set means any error containing msg
+		msg    string
+	}{
+		{name: "valid", method: "GET", now: signedAt.Add(time.Minute)},
+		{name: "last second", method: "GET", now: signedAt.Add(time.Hour)},
+		{name: "expired", method: "GET", now: signedAt.Add(time.Hour + time.Second), err: ErrExpired},
+		{name: "signed in the future", method: "GET", now: signedAt.Add(-time.Hour), err: ErrNotYetValid},
+		{name: "within clock skew", method: "GET", now: signedAt.Add(-MaxClockSkew)},
+		{name: "other method", method: "PUT", now: signedAt, err: ErrSignatureInvalid},
+		{name: "wrong secret", method: "GET", secret: "guess", now: signedAt, err: ErrSignatureInvalid},
+		{name: "other key", method: "GET", now: signedAt, err: ErrSignatureInvalid,
+			edit: func(u *url.URL) { u.Path = "/photos/other.jpg" }},
+		{name: "longer expiry", method: "GET", now: signedAt, err: ErrSignatureInvalid,
+			edit: func(u *url.URL) { setQuery(u, "X-Amz-Expires", "7200") }},
+		{name: "expiry over a week", method: "GET", now: signedAt, msg: "X-Amz-Expires",
+			edit: func(u *url.URL) { setQuery(u, "X-Amz-Expires", "604801") }},
+		{name: "unknown access key", method: "GET", now: signedAt, err: ErrUnknownAccessKey,
+			edit: func(u *url.URL) { setQuery(u, "X-Amz-Credential", "NOBODY/20240501/us-east-1/s3/aws4_request") }},
+		{name: "scope of another day", method: "GET", now: signedAt, msg: "credential scope date",
+			edit: func(u *url.URL) { setQuery(u, "X-Amz-Credential", "AKROOT/20240502/us-east-1/s3/aws4_request") }},
+		{name: "host not signed", method: "GET", now: signedAt, msg: "must include host",
+			edit: func(u *url.URL) { setQuery(u, "X-Amz-SignedHeaders", "x-amz-date") }},
+		{name: "other algorithm", method: "GET", now: signedAt, msg: "unsupported algorithm",
+			edit: func(u *url.URL) { setQuery(u, "X-Amz-Algorithm", "AWS4-HMAC-SHA1") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := tt.secret
+			if secret == "" {
+				secret = "root-secret"
+			}
+			signed, err := PresignURL("GET", "http://s3.local:9000", "photos", "cat.jpg", "AKROOT", secret, time.Hour, signedAt)
+			if err != nil {
+				t.Fatalf("PresignURL: %v", err)
+			}
+			u, _ := url.Parse(signed)
+			if tt.edit != nil {
+				tt.edit(u)
+			}
+
+			r := httptest.NewRequest(tt.method, u.String(), nil)
+			user, err := VerifyPresigned(r, &models.Storage{}, tt.now)
+			switch {
+			case tt.err == nil && tt.msg == "":
+				if err != nil || user != RootUser {
+					t.Fatalf("got %q, %v; want %q", user, err, RootUser)
+				}
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+			default:
+				if err == nil || !strings.Contains(err.Error(), tt.msg) {
+					t.Fatalf("got %v, want an error about %q", err, tt.msg)
+				}
+			}
+		})
+	}
+}
+
+func TestPresignURLExpiry(t *testing.T) {
+	for _, expires := range []time.Duration{0, -time.Second, MaxPresignExpiry + time.Second} {
+		if _, err := PresignURL("GET", "http://s3.local", "b", "k", "AK", "SK", expires, time.Now()); err == nil {
+			t.Errorf("expiry %s was accepted", expires)
+		}
+	}
+	if _, err := PresignURL("GET", "not a url", "b", "k", "AK", "SK", time.Hour, time.Now()); err == nil {
+		t.Error("endpoint without a host was accepted")
+	}
+}
+
+func TestVerifyHeader(t *testing.T) {
+	useRootKey(t)
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		edit func(r *http.Request)
+		err  error
+		msg  string
+	}{
+		{name: "valid", now: signedAt},
+		{name: "clock behind", now: signedAt.Add(-MaxClockSkew)},
+		{name: "clock ahead", now: signedAt.Add(MaxClockSkew)},
+		{name: "too old", now: signedAt.Add(MaxClockSkew + time.Second), err: ErrTimeSkewed},
+		{name: "too new", now: signedAt.Add(-MaxClockSkew - time.Second), err: ErrTimeSkewed},
+		{name: "other path", now: signedAt, err: ErrSignatureInvalid,
+			edit: func(r *http.Request) { r.URL.Path = "/photos/dog.jpg" }},
+		{name: "other query", now: signedAt, err: ErrSignatureInvalid,
+			edit: func(r *http.Request) { r.URL.RawQuery = "acl=" }},
+		{name: "other host", now: signedAt, err: ErrSignatureInvalid,
+			edit: func(r *http.Request) { r.Host = "evil.local" }},
+		{name: "content hash not signed", now: signedAt, msg: "must include host and x-amz-content-sha256",
+			edit: func(r *http.Request) {
+				r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), "host;x-amz-content-sha256;", "host;", 1))
+			}},
+		{name: "bad content hash", now: signedAt, msg: "unsupported x-amz-content-sha256",
+			edit: func(r *http.Request) { r.Header.Set("X-Amz-Content-Sha256", "STREAMING") }},
+		{name: "other scheme", now: signedAt, msg: "unsupported authorization type",
+			edit: func(r *http.Request) { r.Header.Set("Authorization", "AWS AKROOT:c2ln") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://s3.local:9000/photos/cat.jpg", nil)
+			SignRequest(r, "AKROOT", "root-secret", signedAt)
+			if tt.edit != nil {
+				tt.edit(r)
+			}
+
+			user, err := VerifyHeader(r, &models.Storage{}, tt.now)
+			switch {
+			case tt.err == nil && tt.msg == "":
+				if err != nil || user != RootUser {
+					t.Fatalf("got %q, %v; want %q", user, err, RootUser)
+				}
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+			default:
+				if err == nil || !strings.Contains(err.Error(), tt.msg) {
+					t.Fatalf("got %v, want an error about %q", err, tt.msg)
+				}
+			}
+		})
+	}
+}
+
+func TestSignedPayload(t *testing.T) {
+	useRootKey(t)
+	now := time.Now()
+	sum := sha256.Sum256([]byte("hello"))
+
+	tests := []struct {
+		name string
+		body string
+		err  error
+	}{
+		{"signed body", "hello", nil},
+		{"swapped body", "hellO", ErrPayloadMismatch},
+		{"truncated body", "hell", ErrPayloadMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "http://s3.local/photos/cat.txt", strings.NewReader(tt.body))
+			signWithPayload(r, "AKROOT", "root-secret", hex.EncodeToString(sum[:]), now)
+			if _, err := VerifyHeader(r, &models.Storage{}, now); err != nil {
+				t.Fatalf("VerifyHeader: %v", err)
+			}
+			if _, err := io.ReadAll(r.Body); !errors.Is(err, tt.err) {
+				t.Fatalf("reading the body: got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// signWithPayload is SignRequest with a signed content hash
+func signWithPayload(r *http.Request, accessKey, secretKey, payloadHash string, now time.Time) {
+	now = now.UTC()
+	scope := credentialScope(now)
+	r.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, signedHeaders),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := sign(secretKey, now, stringToSign(now, scope, canonical))
+	r.Header.Set("Authorization", Algorithm+" Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func setQuery(u *url.URL, name, value string) {
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+}
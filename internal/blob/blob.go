@@ -0,0 +1,131 @@
+package blob
+
+import (
+	"A3S/internal/compress"
+	"A3S/internal/models"
+	"A3S/internal/sse"
+	"io"
+	"os"
+)
+
+// ReadSeekCloser is the plaintext view of a stored object
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// Write stores body as the data of object, compressing and then encrypting it
+// as object.Compression and object.Encryption ask (with customerKey instead of
+// the master key for SSE-C), and fills in its Size, StoredSize and Chunks.
+// The chunks are referenced until Release is called on the object; those of
+// encrypted objects are not deduplicated.
+func Write(object *models.Object, body io.Reader, customerKey *sse.CustomerKey) error {
+	chunks := &chunkWriter{buf: make([]byte, 0, ChunkSize), unique: object.Encryption != ""}
+
+	size, err := write(chunks, body, object, customerKey)
+	if err == nil {
+		err = chunks.Close()
+	}
+	if err != nil {
+		release(chunks.hashes)
+		return err
+	}
+
+	object.Size = int(size)
+	object.StoredSize = int(chunks.size)
+	object.Chunks = chunks.hashes
+	return nil
+}
+
+func write(dst io.Writer, body io.Reader, object *models.Object, customerKey *sse.CustomerKey) (int64, error) {
+	// closers run innermost first, so the compressor flushes into the cipher
+	var closers []io.Closer
+
+	if object.Encryption != "" {
+		var writer *sse.Writer
+		var err error
+		if customerKey != nil {
+			writer, err = sse.NewCustomerWriter(dst, customerKey)
+		} else {
+			writer, err = sse.NewWriter(dst)
+		}
+		if err != nil {
+			return 0, err
+		}
+		dst = writer
+		closers = append(closers, writer)
+	}
+
+	if object.Compression != "" {
+		writer := compress.NewWriter(dst)
+		dst = writer
+		closers = append(closers, writer)
+	}
+
+	written, err := io.Copy(dst, body)
+	if err != nil {
+		return written, err
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+type objectReader struct {
+	io.ReadSeeker
+	io.Closer
+}
+
+// Open returns the plaintext of object and its size; customerKey must be the
+// key an SSE-C object was written with
+func Open(object *models.Object, customerKey *sse.CustomerKey) (ReadSeekCloser, int64, error) {
+	var src interface {
+		io.ReaderAt
+		io.Closer
+	}
+	var size int64
+
+	if object.Chunks == nil {
+		// stored before chunking, as a plain file at the object's path
+		file, err := os.Open(object.ObjectKey)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		src, size = file, info.Size()
+	} else {
+		src = newChunkReader(object.Chunks)
+		size = int64(object.StoredSize)
+	}
+
+	var reader io.ReadSeeker = io.NewSectionReader(src, 0, size)
+
+	if object.Encryption != "" {
+		var decrypted *sse.Reader
+		var err error
+		if customerKey != nil {
+			decrypted, err = sse.NewCustomerReader(src, size, customerKey)
+		} else {
+			decrypted, err = sse.NewReader(src, size)
+		}
+		if err != nil {
+			src.Close()
+			return nil, 0, err
+		}
+		reader, size = decrypted, decrypted.Size()
+	}
+
+	if object.Compression != "" {
+		size = int64(object.Size)
+		reader = compress.NewReader(reader, size)
+	}
+
+	return objectReader{ReadSeeker: reader, Closer: src}, size, nil
+}
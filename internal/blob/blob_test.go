@@ -0,0 +1,278 @@
+package blob
+
+import (
+	"A3S/internal/compress"
+	"A3S/internal/models"
+	"A3S/internal/sse"
+	"A3S/internal/utils"
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTempDir points the data directory at a fresh directory and empties
+// the reference counts
+func useTempDir(t *testing.T) {
+	t.Helper()
+	dir := *utils.Dir
+	*utils.Dir = t.TempDir()
+	t.Cleanup(func() { *utils.Dir = dir })
+	if err := Load(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedChunks counts the chunk files on disk
+func storedChunks(t *testing.T) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(chunkDir(), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func randomData(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func readAll(t *testing.T, object *models.Object, key *sse.CustomerKey) []byte {
+	t.Helper()
+	r, size, err := Open(object, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if int64(len(data)) != size {
+		t.Fatalf("Open reported %d bytes, read %d", size, len(data))
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	useTempDir(t)
+	key := &sse.CustomerKey{Key: bytes.Repeat([]byte{9}, 32)}
+
+	tests := []struct {
+		name        string
+		size        int
+		compression string
+		encryption  string
+		chunks      int
+	}{
+		{name: "empty", size: 0, chunks: 1},
+		{name: "one byte", size: 1, chunks: 1},
+		{name: "one full chunk", size: ChunkSize, chunks: 1},
+		{name: "one past a chunk", size: ChunkSize + 1, chunks: 2},
+		{name: "compressed", size: 3 * ChunkSize / 2, compression: compress.Gzip},
+		{name: "encrypted", size: ChunkSize + 10, encryption: sse.AES256},
+		{name: "compressed and encrypted", size: ChunkSize + 10, compression: compress.Gzip, encryption: sse.AES256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := randomData(t, tt.size)
+			object := &models.Object{Compression: tt.compression, Encryption: tt.encryption}
+			var customerKey *sse.CustomerKey
+			if tt.encryption != "" {
+				customerKey = key
+			}
+			if err := Write(object, bytes.NewReader(data), customerKey); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if object.Size != tt.size {
+				t.Errorf("Size = %d, want %d", object.Size, tt.size)
+			}
+			if tt.chunks > 0 && len(object.Chunks) != tt.chunks {
+				t.Errorf("%d chunks, want %d", len(object.Chunks), tt.chunks)
+			}
+			if !bytes.Equal(readAll(t, object, customerKey), data) {
+				t.Fatal("data read back differs")
+			}
+			if err := Release(object); err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+		})
+	}
+	if n := storedChunks(t); n != 0 {
+		t.Fatalf("%d chunks left after releasing every object", n)
+	}
+}
+
+func TestDeduplication(t *testing.T) {
+	useTempDir(t)
+	shared := randomData(t, ChunkSize)
+	first := &models.Object{}
+	second := &models.Object{}
+
+	if err := Write(first, bytes.NewReader(append(bytes.Clone(shared), 'a')), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(second, bytes.NewReader(append(bytes.Clone(shared), 'b')), nil); err != nil {
+		t.Fatal(err)
+	}
+	if first.Chunks[0] != second.Chunks[0] {
+		t.Fatal("identical chunks were stored twice")
+	}
+	if n := storedChunks(t); n != 3 {
+		t.Fatalf("%d chunks stored, want 3", n)
+	}
+
+	if err := Release(first); err != nil {
+		t.Fatal(err)
+	}
+	if n := storedChunks(t); n != 2 {
+		t.Fatalf("%d chunks left, want the shared one and the tail of the second object", n)
+	}
+	if got := readAll(t, second, nil); !bytes.Equal(got[:ChunkSize], shared) {
+		t.Fatal("shared chunk lost its data")
+	}
+	if err := Release(second); err != nil {
+		t.Fatal(err)
+	}
+	if n := storedChunks(t); n != 0 {
+		t.Fatalf("%d chunks left", n)
+	}
+}
+
+func TestEncryptedChunksAreNotShared(t *testing.T) {
+	useTempDir(t)
+	key := &sse.CustomerKey{Key: bytes.Repeat([]byte{5}, 32)}
+	data := randomData(t, 100)
+
+	first := &models.Object{Encryption: sse.AES256}
+	second := &models.Object{Encryption: sse.AES256}
+	for _, o := range []*models.Object{first, second} {
+		if err := Write(o, bytes.NewReader(data), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.Chunks[0] == second.Chunks[0] {
+		t.Fatal("encrypted objects share a chunk")
+	}
+	if err := Release(first); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readAll(t, second, key), data) {
+		t.Fatal("data read back differs")
+	}
+}
+
+func TestReaderKeepsReleasedChunks(t *testing.T) {
+	useTempDir(t)
+	data := randomData(t, 2*ChunkSize+5)
+	object := &models.Object{}
+	if err := Write(object, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _, err := Open(object, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the object is overwritten or deleted before the GET reads anything
+	if err := Release(object); err != nil {
+		t.Fatal(err)
+	}
+	if n := storedChunks(t); n != 3 {
+		t.Fatalf("%d chunks left while a reader is open, want 3", n)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading released chunks: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data read back differs")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := storedChunks(t); n != 0 {
+		t.Fatalf("%d chunks left after the reader closed", n)
+	}
+}
+
+func TestReaderLeavesReferencedChunks(t *testing.T) {
+	useTempDir(t)
+	object := &models.Object{}
+	if err := Write(object, bytes.NewReader(randomData(t, 10)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		r, _, err := Open(object, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := storedChunks(t); n != 1 {
+		t.Fatalf("%d chunks left, want the object's one", n)
+	}
+}
+
+func TestLoadRemovesOrphans(t *testing.T) {
+	useTempDir(t)
+	kept := &models.Object{}
+	orphan := &models.Object{}
+	if err := Write(kept, bytes.NewReader([]byte("kept")), nil); err != nil {
+		t.Fatal(err)
+	}
+	// an upload interrupted by a crash leaves chunks without an object
+	if err := Write(orphan, bytes.NewReader([]byte("orphan")), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Load([]models.Object{*kept}); err != nil {
+		t.Fatal(err)
+	}
+	if n := storedChunks(t); n != 1 {
+		t.Fatalf("%d chunks left, want 1", n)
+	}
+	if string(readAll(t, kept, nil)) != "kept" {
+		t.Fatal("referenced chunk was removed")
+	}
+}
+
+func TestPlainFileObjects(t *testing.T) {
+	useTempDir(t)
+	path := filepath.Join(*utils.Dir, "legacy")
+	if err := os.WriteFile(path, []byte("stored before chunking"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	object := &models.Object{ObjectKey: path}
+
+	if got := readAll(t, object, nil); string(got) != "stored before chunking" {
+		t.Fatalf("got %q", got)
+	}
+	if err := Release(object); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("plain file was not removed")
+	}
+	if err := Release(object); err != nil {
+		t.Fatalf("releasing twice: %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package blob
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ChunkSize is how object data is split before it is hashed; identical chunks,
+// in any object of any bucket, are stored once. Encrypted objects don't
+// deduplicate: every object gets its own data key, so their chunks are never
+// identical and are stored under random names instead of being hashed.
+const ChunkSize = 4 * 1024 * 1024
+
+// chunkDir can't collide with a bucket, bucket names never start with a dot
+func chunkDir() string {
+	return utils.DataPath(".chunks")
+}
+
+// refs counts the objects referencing each chunk. It is rebuilt from object
+// metadata by Load, so it never has to be persisted itself. readers counts
+// the open readers of each chunk: a chunk no object references any more is
+// only removed once the last of them is closed, as GETs stream without the
+// storage lock and chunks are opened as the reads reach them.
+var (
+	refs    = map[string]int{}
+	readers = map[string]int{}
+	refsMu  sync.Mutex
+)
+
+func chunkPath(hash string) string {
+	return filepath.Join(chunkDir(), hash[:2], hash)
+}
+
+// Load counts the chunk references of all objects and removes chunks nothing
+// references, such as those left behind by an upload interrupted by a crash
+func Load(objects []models.Object) error {
+	refsMu.Lock()
+	defer refsMu.Unlock()
+
+	refs = map[string]int{}
+	for _, o := range objects {
+		for _, hash := range o.Chunks {
+			refs[hash]++
+		}
+	}
+
+	freed := 0
+	err := filepath.WalkDir(chunkDir(), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if refs[d.Name()] == 0 {
+			freed++
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if freed > 0 {
+		log.Printf("Removed %d unreferenced chunks", freed)
+	}
+	return err
+}
+
+// putChunk stores data under its hash unless an identical chunk already
+// exists, and takes a reference on it
+func putChunk(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	refsMu.Lock()
+	defer refsMu.Unlock()
+
+	if refs[hash] == 0 {
+		if err := writeChunk(chunkPath(hash), data); err != nil {
+			return "", err
+		}
+	}
+	refs[hash]++
+	return hash, nil
+}
+
+// putUniqueChunk stores data under a random name, for chunks that can't have
+// an identical twin
+func putUniqueChunk(data []byte) (string, error) {
+	id := make([]byte, sha256.Size)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	name := hex.EncodeToString(id)
+
+	refsMu.Lock()
+	defer refsMu.Unlock()
+
+	if err := writeChunk(chunkPath(name), data); err != nil {
+		return "", err
+	}
+	refs[name]++
+	return name, nil
+}
+
+func writeChunk(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".chunk-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := file.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Release drops the references object holds on its chunks and garbage collects
+// the ones no other object uses. Objects stored before chunking was introduced
+// are plain files, which are removed instead.
+func Release(object *models.Object) error {
+	if object.Chunks == nil {
+		err := os.Remove(object.ObjectKey)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return release(object.Chunks)
+}
+
+func release(hashes []string) error {
+	refsMu.Lock()
+	defer refsMu.Unlock()
+
+	var firstErr error
+	for _, hash := range hashes {
+		refs[hash]--
+		if refs[hash] > 0 {
+			continue
+		}
+		delete(refs, hash)
+		if readers[hash] > 0 {
+			continue
+		}
+		if err := os.Remove(chunkPath(hash)); err != nil && !errors.Is(err, os.ErrNotExist) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// chunkWriter cuts everything written to it into chunks; unique chunks skip
+// the hashing
+type chunkWriter struct {
+	buf    []byte
+	hashes []string
+	size   int64
+	unique bool
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(c.buf[len(c.buf):cap(c.buf)], p)
+		c.buf = c.buf[:len(c.buf)+n]
+		p = p[n:]
+		written += n
+		if len(c.buf) == cap(c.buf) {
+			if err := c.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (c *chunkWriter) flush() error {
+	put := putChunk
+	if c.unique {
+		put = putUniqueChunk
+	}
+	hash, err := put(c.buf)
+	if err != nil {
+		return err
+	}
+	c.hashes = append(c.hashes, hash)
+	c.size += int64(len(c.buf))
+	c.buf = c.buf[:0]
+	return nil
+}
+
+// Close stores the last, partial chunk; an empty object still gets one empty
+// chunk, which tells it apart from objects stored as plain files
+func (c *chunkWriter) Close() error {
+	if len(c.buf) > 0 || len(c.hashes) == 0 {
+		return c.flush()
+	}
+	return nil
+}
+
+// chunkReader reads the concatenation of a chunk list
+type chunkReader struct {
+	hashes []string
+	files  map[int]*os.File
+}
+
+// newChunkReader keeps the chunks of hashes on disk until the reader is
+// closed; it must be called while an object still references them
+func newChunkReader(hashes []string) *chunkReader {
+	refsMu.Lock()
+	defer refsMu.Unlock()
+	for _, hash := range hashes {
+		readers[hash]++
+	}
+	return &chunkReader{hashes: hashes, files: map[int]*os.File{}}
+}
+
+func (c *chunkReader) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for len(p) > 0 {
+		index := int(off / ChunkSize)
+		if index >= len(c.hashes) {
+			return read, io.EOF
+		}
+
+		file, err := c.open(index)
+		if err != nil {
+			return read, err
+		}
+		n, err := file.ReadAt(p, off%ChunkSize)
+		read += n
+		off += int64(n)
+		p = p[n:]
+		// running off the end of a chunk moves on to the next one
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+		if err == io.EOF && n == 0 {
+			return read, io.EOF
+		}
+	}
+	return read, nil
+}
+
+func (c *chunkReader) open(index int) (*os.File, error) {
+	if file, ok := c.files[index]; ok {
+		return file, nil
+	}
+	file, err := os.Open(chunkPath(c.hashes[index]))
+	if err != nil {
+		return nil, err
+	}
+	c.files[index] = file
+	return file, nil
+}
+
+// Close also removes the chunks that lost their last reference while they
+// were being read
+func (c *chunkReader) Close() error {
+	for _, file := range c.files {
+		file.Close()
+	}
+
+	refsMu.Lock()
+	defer refsMu.Unlock()
+	var firstErr error
+	for _, hash := range c.hashes {
+		readers[hash]--
+		if readers[hash] > 0 {
+			continue
+		}
+		delete(readers, hash)
+		if refs[hash] > 0 {
+			continue
+		}
+		if err := os.Remove(chunkPath(hash)); err != nil && !errors.Is(err, os.ErrNotExist) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.hashes, c.files = nil, nil
+	return firstErr
+}
@@ -0,0 +1,28 @@
+package bucketname
+
+import (
+	"A3S/internal/s3err"
+	"errors"
+	"net"
+	"regexp"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9]([a-z0-9\-\.]{1,61}[a-z0-9])?$`)
+
+// Validate applies the S3 bucket naming rules, which also keep a name from
+// leaving the storage directory
+func Validate(name string) (s3err.Error, error) {
+	if len(name) < 3 || len(name) > 63 {
+		return s3err.InvalidBucketName, errors.New("bucket name must be between 3 and 63 characters")
+	}
+
+	if !validName.MatchString(name) {
+		return s3err.InvalidBucketName, errors.New("bucket name must only contain lowercase letters, numbers, hyphens, and periods")
+	}
+
+	if net.ParseIP(name) != nil {
+		return s3err.InvalidBucketName, errors.New("bucket name must not be formatted as an IP address")
+	}
+
+	return s3err.Error{}, nil
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"A3S/internal/utils"
+	"fmt"
+	"os"
+)
+
+// Run dispatches a subcommand such as "presign" or "user" and exits on failure
+func Run(args []string) {
+	switch args[0] {
+	case "presign":
+		Presign(args[1:])
+	case "user":
+		User(args[1:])
+	case "key":
+		Key(args[1:])
+	default:
+		fmt.Printf("Unknown command: %s\n", args[0])
+		fmt.Println(utils.HelpFlag())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"A3S/internal/auth"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// adminFlags are shared by every subcommand that talks to the admin API
+type adminFlags struct {
+	endpoint  *string
+	accessKey *string
+	secretKey *string
+}
+
+func newAdminFlags(fs *flag.FlagSet) adminFlags {
+	return adminFlags{
+		endpoint:  fs.String("endpoint", "http://localhost:8080", "Server address"),
+		accessKey: fs.String("access-key", "", "Root access key"),
+		secretKey: fs.String("secret-key", "", "Root secret key"),
+	}
+}
+
+// do sends a signed admin request and prints the response body
+func (a adminFlags) do(method, path string) {
+	body, status := a.request(method, path, nil)
+	fmt.Println(string(body))
+	if status >= 300 {
+		os.Exit(1)
+	}
+}
+
+// request sends a signed admin request and returns the response body and
+// status, it exits when the server cannot be reached
+func (a adminFlags) request(method, path string, query url.Values) ([]byte, int) {
+	if *a.accessKey == "" || *a.secretKey == "" {
+		fmt.Println("-access-key and -secret-key are required")
+		os.Exit(1)
+	}
+
+	u, err := url.Parse(*a.endpoint)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	auth.SignRequest(req, *a.accessKey, *a.secretKey, time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	return body, resp.StatusCode
+}
+
+// User handles "user <list|get|create|delete|enable|disable> [-name N]"
+func User(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: user <list|get|create|delete|enable|disable> [-name N]")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("user", flag.ExitOnError)
+	admin := newAdminFlags(fs)
+	name := fs.String("name", "", "User name")
+	fs.Parse(args[1:])
+
+	if args[0] != "list" && *name == "" {
+		fmt.Println("-name is required")
+		os.Exit(1)
+	}
+
+	path := "/_admin/users/" + *name
+	switch args[0] {
+	case "list":
+		admin.do(http.MethodGet, "/_admin/users")
+	case "get":
+		admin.do(http.MethodGet, path)
+	case "create":
+		admin.do(http.MethodPut, path)
+	case "delete":
+		admin.do(http.MethodDelete, path)
+	case "enable", "disable":
+		admin.do(http.MethodPost, path+"/"+args[0])
+	default:
+		fmt.Printf("Unknown user command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// Key handles "key <list|create> -user U" and "key <enable|disable|rotate|delete> -id K"
+func Key(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: key <list|create> -user U | key <enable|disable|rotate|delete> -id K")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("key", flag.ExitOnError)
+	admin := newAdminFlags(fs)
+	user := fs.String("user", "", "Owner of the access keys")
+	id := fs.String("id", "", "Access key id")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "list", "create":
+		if *user == "" {
+			fmt.Println("-user is required")
+			os.Exit(1)
+		}
+		method := http.MethodGet
+		if args[0] == "create" {
+			method = http.MethodPost
+		}
+		admin.do(method, "/_admin/users/"+*user+"/keys")
+	case "enable", "disable", "rotate", "delete":
+		if *id == "" {
+			fmt.Println("-id is required")
+			os.Exit(1)
+		}
+		if args[0] == "delete" {
+			admin.do(http.MethodDelete, "/_admin/keys/"+*id)
+		} else {
+			admin.do(http.MethodPost, "/_admin/keys/"+*id+"/"+args[0])
+		}
+	default:
+		fmt.Printf("Unknown key command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"A3S/internal/models"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// Presign asks the server for a URL signed with an access key of -user; the
+// root credentials only authorise the request and never end up in the URL
+func Presign(args []string) {
+	fs := flag.NewFlagSet("presign", flag.ExitOnError)
+	admin := newAdminFlags(fs)
+	user := fs.String("user", "", "IAM user the URL acts as, signed with one of their active access keys")
+	method := fs.String("method", "GET", "HTTP method the URL is valid for (GET or PUT)")
+	bucket := fs.String("bucket", "", "Bucket name")
+	key := fs.String("key", "", "Object key")
+	expires := fs.Int("expires", 3600, "Seconds until the URL expires")
+	fs.Parse(args)
+
+	if *bucket == "" || *key == "" || *user == "" {
+		fmt.Println("presign requires -bucket, -key and -user")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *method != "GET" && *method != "PUT" {
+		fmt.Println("presign -method must be GET or PUT")
+		os.Exit(1)
+	}
+
+	query := url.Values{}
+	query.Set("user", *user)
+	query.Set("bucket", *bucket)
+	query.Set("key", *key)
+	query.Set("method", *method)
+	query.Set("expires", strconv.Itoa(*expires))
+
+	body, status := admin.request(http.MethodPost, "/_admin/presign", query)
+	if status >= 300 {
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+	var presigned models.PresignedURL
+	if err := xml.Unmarshal(body, &presigned); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	fmt.Println(presigned.URL)
+}
@@ -0,0 +1,148 @@
+package compress
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Gzip is the only algorithm available from the standard library
+const Gzip = "gzip"
+
+const MaxConfigSize = 64 * 1024
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "CompressionConfiguration.xml")
+}
+
+// Load returns the bucket's compression configuration, or nil if objects are
+// stored as uploaded
+func Load(bucket string) (*models.CompressionConfiguration, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Save(bucket string, config *models.CompressionConfiguration) error {
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(bucket), data, 0o644)
+}
+
+func Delete(bucket string) error {
+	err := os.Remove(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func Parse(data []byte) (*models.CompressionConfiguration, error) {
+	var config models.CompressionConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	if config.Algorithm != Gzip {
+		return nil, fmt.Errorf("unsupported Algorithm %q, only %s is available", config.Algorithm, Gzip)
+	}
+	return &config, nil
+}
+
+// Resolve returns the algorithm new objects of bucket are compressed with, "" for none
+func Resolve(bucket string) (string, error) {
+	config, err := Load(bucket)
+	if err != nil || config == nil {
+		return "", err
+	}
+	return config.Algorithm, nil
+}
+
+// NewWriter compresses everything written to it into dst; Close flushes the
+// stream but does not close dst
+func NewWriter(dst io.Writer) io.WriteCloser {
+	return gzip.NewWriter(dst)
+}
+
+// Reader decompresses an object of a known logical size. gzip streams can't be
+// seeked, so a seek only moves the position: reading forward skips data, and
+// reading backwards restarts from the beginning of the stream. That keeps Range
+// requests working, at the cost of decompressing everything before the range.
+type Reader struct {
+	src    io.ReadSeeker
+	size   int64
+	offset int64
+
+	gz       *gzip.Reader
+	position int64
+}
+
+func NewReader(src io.ReadSeeker, size int64) *Reader {
+	return &Reader{src: src, size: size}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.gz == nil || r.position > r.offset {
+		if err := r.restart(); err != nil {
+			return 0, err
+		}
+	}
+	if r.position < r.offset {
+		skipped, err := io.CopyN(io.Discard, r.gz, r.offset-r.position)
+		r.position += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.gz.Read(p)
+	r.position += int64(n)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *Reader) restart() error {
+	if _, err := r.src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var err error
+	if r.gz == nil {
+		r.gz, err = gzip.NewReader(r.src)
+	} else {
+		err = r.gz.Reset(r.src)
+	}
+	r.position = 0
+	return err
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
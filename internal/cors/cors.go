@@ -0,0 +1,233 @@
+package cors
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// limits enforced by S3 on CORS configurations
+	MaxConfigSize = 64 * 1024
+	MaxRules      = 100
+)
+
+var allowedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPut:    true,
+	http.MethodPost:   true,
+	http.MethodDelete: true,
+	http.MethodHead:   true,
+}
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "CORSConfiguration.xml")
+}
+
+// Load returns the bucket's CORS configuration, or nil if none is set
+func Load(bucket string) (*models.CORSConfiguration, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Save(bucket string, config *models.CORSConfiguration) error {
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(bucket), data, 0o644)
+}
+
+func Delete(bucket string) error {
+	err := os.Remove(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Parse decodes a CORSConfiguration document and validates its rules
+func Parse(data []byte) (*models.CORSConfiguration, error) {
+	var config models.CORSConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+
+	if len(config.CORSRules) == 0 {
+		return nil, errors.New("at least one CORSRule is required")
+	}
+	if len(config.CORSRules) > MaxRules {
+		return nil, fmt.Errorf("at most %d CORSRules are allowed", MaxRules)
+	}
+
+	for i, rule := range config.CORSRules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return nil, fmt.Errorf("rule %d: AllowedOrigin and AllowedMethod are required", i)
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return nil, fmt.Errorf("rule %d: AllowedOrigin %q can contain at most one wildcard", i, origin)
+			}
+		}
+		for _, method := range rule.AllowedMethods {
+			if !allowedMethods[method] {
+				return nil, fmt.Errorf("rule %d: unsupported AllowedMethod %q", i, method)
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return nil, fmt.Errorf("rule %d: AllowedHeader %q can contain at most one wildcard", i, header)
+			}
+		}
+		if rule.MaxAgeSeconds < 0 {
+			return nil, fmt.Errorf("rule %d: MaxAgeSeconds must not be negative", i)
+		}
+	}
+
+	return &config, nil
+}
+
+// MatchRule returns the first rule allowing origin to send method with headers
+func MatchRule(config *models.CORSConfiguration, origin, method string, headers []string) *models.CORSRule {
+	for i, rule := range config.CORSRules {
+		if !matchAny(rule.AllowedOrigins, origin, false) {
+			continue
+		}
+		if !matchAny(rule.AllowedMethods, method, false) {
+			continue
+		}
+
+		allHeaders := true
+		for _, header := range headers {
+			if !matchAny(rule.AllowedHeaders, header, true) {
+				allHeaders = false
+				break
+			}
+		}
+		if allHeaders {
+			return &config.CORSRules[i]
+		}
+	}
+	return nil
+}
+
+// Preflight answers an OPTIONS request from a browser
+func Preflight(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if origin == "" || method == "" {
+		s3err.Write(w, r, s3err.InvalidRequest, "Insufficient information. Origin request header needed.")
+		return
+	}
+
+	config, err := Load(bucket)
+	if err != nil {
+		log.Printf("Error reading CORS configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error reading CORS configuration")
+		return
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.AccessForbidden, "CORSResponse: CORS is not enabled for this bucket.")
+		return
+	}
+
+	var headers []string
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, strings.ToLower(h))
+		}
+	}
+
+	rule := MatchRule(config, origin, method, headers)
+	if rule == nil {
+		s3err.Write(w, r, s3err.AccessForbidden, "")
+		return
+	}
+
+	writeAllowOrigin(w, rule, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if rule.MaxAgeSeconds > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+	}
+	w.Header().Add("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+	w.WriteHeader(http.StatusOK)
+}
+
+// ApplyHeaders adds CORS response headers to a regular request coming from
+// a browser, if one of the bucket's rules allows it. It must run before the
+// handler writes its response.
+func ApplyHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+
+	config, err := Load(r.PathValue("bucket"))
+	if err != nil || config == nil {
+		return
+	}
+
+	rule := MatchRule(config, origin, r.Method, nil)
+	if rule == nil {
+		return
+	}
+
+	writeAllowOrigin(w, rule, origin)
+	if len(rule.ExposeHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	w.Header().Add("Vary", "Origin")
+}
+
+// writeAllowOrigin answers "*" for wildcard rules and echoes specific origins,
+// which also lets the browser send credentials
+func writeAllowOrigin(w http.ResponseWriter, rule *models.CORSRule, origin string) {
+	for _, allowed := range rule.AllowedOrigins {
+		if allowed == "*" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+}
+
+func matchAny(patterns []string, value string, foldCase bool) bool {
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+		}
+		if matchWildcard(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard matches a pattern containing at most one '*'
+func matchWildcard(pattern, value string) bool {
+	prefix, suffix, found := strings.Cut(pattern, "*")
+	if !found {
+		return pattern == value
+	}
+	return len(value) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix)
+}
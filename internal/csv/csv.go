@@ -1,19 +1,26 @@
 package csv
 
 import (
+	"A3S/internal/metrics"
 	"A3S/internal/models"
+	"A3S/internal/utils"
+	"bytes"
 	"encoding/csv"
+	"errors"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 func CSVBucketWriter(bucket *models.Bucket) {
-	dataDir := "data"
-	metaFilePath := dataDir + "/BucketMetaData.csv"
+	defer metrics.ObserveMetadata("bucket_write", time.Now())
+
+	metaFilePath := utils.DataPath("BucketMetaData.csv")
 
 	// open file to write data
 	metaFile, err := os.OpenFile(metaFilePath, os.O_RDWR|os.O_CREATE, 0o644)
@@ -28,7 +35,7 @@ func CSVBucketWriter(bucket *models.Bucket) {
 	}
 	// Writeing header to CSV if empty
 	if info.Size() == 0 {
-		_, err = metaFile.WriteString("Name,CreationTime,LastModifiedTime,Status\n")
+		_, err = metaFile.WriteString("Name,CreationTime,LastModifiedTime,Status,Owner,ACL,Objects,Bytes,MaxObjects,MaxBytes,SoftMaxObjects,SoftMaxBytes\n")
 		if err != nil {
 			log.Fatal("Can't create Header in CSV:", err)
 		}
@@ -44,21 +51,16 @@ func CSVBucketWriter(bucket *models.Bucket) {
 		log.Fatal("error:", err)
 	}
 
-	// Creating a row with info about bucket
-	row := []string{
-		bucket.Name,
-		bucket.CreationTime.Format(time.RFC3339),
-		bucket.LastModified.Format(time.RFC3339),
-		bucket.Status,
-	}
 	// Writing data row CSV
-	if err := writer.Write(row); err != nil {
+	if err := writer.Write(bucketRecord(bucket)); err != nil {
 		log.Fatal("Could not write bucket data to CSV:", err)
 	}
 }
 
-func CSVDBucketDelete(bucket *models.Bucket) {
-	metaFilePath := "data/BucketMetaData.csv"
+func CSVDBucketDelete(bucketName string) {
+	defer metrics.ObserveMetadata("bucket_delete", time.Now())
+
+	metaFilePath := utils.DataPath("BucketMetaData.csv")
 
 	metaFile, err := os.OpenFile(metaFilePath, os.O_RDWR, 0o644)
 	if err != nil {
@@ -68,6 +70,7 @@ func CSVDBucketDelete(bucket *models.Bucket) {
 
 	// Read CSV
 	reader := csv.NewReader(metaFile)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
 		log.Fatal("Error reading CSV file: ", err)
@@ -76,7 +79,7 @@ func CSVDBucketDelete(bucket *models.Bucket) {
 	bucketIndex := -1
 	// searching index bucket to delete it
 	for i, record := range records {
-		if record[0] == bucket.Name {
+		if record[0] == bucketName {
 			bucketIndex = i
 			break
 		}
@@ -84,7 +87,7 @@ func CSVDBucketDelete(bucket *models.Bucket) {
 
 	// if bucket doesn't found outputing info
 	if bucketIndex == -1 {
-		log.Println("Bucket not found in CSV:", bucket.Name)
+		log.Println("Bucket not found in CSV:", bucketName)
 		return
 	}
 
@@ -106,9 +109,22 @@ func CSVDBucketDelete(bucket *models.Bucket) {
 	}
 }
 
+// objectsMu serialises the rewrites of ObjectMetaData.csv files, which also
+// happen from background workers
+var objectsMu sync.Mutex
+
+// objectHeader heads ObjectMetaData.csv. Keys are stored relative to the
+// bucket, so the data directory can move; files whose first column is still
+// called ObjectKey hold whole paths and are migrated when they are loaded.
+var objectHeader = []string{"Key", "Size", "ContentType", "LastModifiedTime", "ACL", "Encryption", "CustomerKeyFingerprint",
+	"Compression", "StoredSize", "Chunks", "Tags", "ReplicationStatus", "LockMode", "RetainUntil", "LegalHold"}
+
 func CSVObjectWriter(object *models.Object, bucketName string) {
-	dataDir := "data/"
-	metaFilePath := dataDir + bucketName + "/ObjectMetaData.csv"
+	defer metrics.ObserveMetadata("object_write", time.Now())
+	objectsMu.Lock()
+	defer objectsMu.Unlock()
+
+	metaFilePath := utils.DataPath(bucketName, "ObjectMetaData.csv")
 
 	metaFile, err := os.OpenFile(metaFilePath, os.O_RDWR|os.O_CREATE, 0o644)
 	if err != nil {
@@ -121,7 +137,7 @@ func CSVObjectWriter(object *models.Object, bucketName string) {
 	}
 	// Adding header if its empty
 	if info.Size() == 0 {
-		_, err = metaFile.WriteString("ObjectKey,Size,ContentType,LastModifiedTime\n")
+		_, err = metaFile.WriteString(strings.Join(objectHeader, ",") + "\n")
 		if err != nil {
 			log.Fatal("Can't create Header in CSV:", err)
 		}
@@ -137,19 +153,18 @@ func CSVObjectWriter(object *models.Object, bucketName string) {
 	if err != nil {
 		log.Fatal("error:", err)
 	}
-	row := []string{
-		object.ObjectKey,
-		strconv.Itoa(object.Size),
-		object.ContentType,
-		object.LastModified.Format(time.RFC3339),
-	}
+	row := objectRecord(object, bucketName)
 	if err := writer.Write(row); err != nil {
 		log.Fatal("Could not write object data to CSV:", err)
 	}
 }
 
 func CSVDeleteObject(object *models.Object, bucketName string) {
-	metaFilePath := filepath.Join("data", bucketName, "ObjectMetaData.csv")
+	defer metrics.ObserveMetadata("object_delete", time.Now())
+	objectsMu.Lock()
+	defer objectsMu.Unlock()
+
+	metaFilePath := utils.DataPath(bucketName, "ObjectMetaData.csv")
 
 	metaFile, err := os.OpenFile(metaFilePath, os.O_RDWR, 0o644)
 	if err != nil {
@@ -158,15 +173,17 @@ func CSVDeleteObject(object *models.Object, bucketName string) {
 	defer metaFile.Close()
 
 	reader := csv.NewReader(metaFile)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
 		log.Fatalf("Error reading CSV file: %v", err)
 	}
 
-	// searching key and indexing it
+	// searching key and indexing it, past the header
+	key := storedKey(object.ObjectKey, bucketName)
 	objectIndex := -1
 	for i, record := range records {
-		if record[0] == object.ObjectKey {
+		if i > 0 && record[0] == key {
 			objectIndex = i
 			log.Printf("Object '%s' found at index %d in CSV", object.ObjectKey, i)
 			break
@@ -199,8 +216,124 @@ func CSVDeleteObject(object *models.Object, bucketName string) {
 	log.Printf("CSV updated successfully after deleting object '%s'", object.ObjectKey)
 }
 
+// storedKey is the key of an object as ObjectMetaData.csv has it
+func storedKey(objectKey, bucketName string) string {
+	return filepath.ToSlash(strings.TrimPrefix(objectKey, utils.DataPath(bucketName)+string(filepath.Separator)))
+}
+
+// legacyKey turns a key written by older versions, the whole path of the
+// object under the data directory of the time, into one relative to bucket
+func legacyKey(path, bucketName string) string {
+	path = filepath.ToSlash(path)
+	if key, ok := strings.CutPrefix(path, filepath.ToSlash(utils.DataPath(bucketName))+"/"); ok {
+		return key
+	}
+	// the data directory has moved since; the key follows the bucket
+	if _, key, ok := strings.Cut("/"+path, "/"+bucketName+"/"); ok {
+		return key
+	}
+	return path
+}
+
+// objectRecord is the row of object in ObjectMetaData.csv
+func objectRecord(object *models.Object, bucketName string) []string {
+	retainUntil, legalHold := "", ""
+	if !object.RetainUntil.IsZero() {
+		retainUntil = object.RetainUntil.Format(time.RFC3339)
+	}
+	if object.LegalHold {
+		legalHold = "ON"
+	}
+	return []string{
+		storedKey(object.ObjectKey, bucketName),
+		strconv.Itoa(object.Size),
+		object.ContentType,
+		object.LastModified.Format(time.RFC3339),
+		object.ACL,
+		object.Encryption,
+		object.CustomerKeyFingerprint,
+		object.Compression,
+		strconv.Itoa(object.StoredSize),
+		strings.Join(object.Chunks, " "),
+		object.Tags,
+		object.ReplicationStatus,
+		object.LockMode,
+		retainUntil,
+		legalHold,
+	}
+}
+
+// CSVUpdateObjectMetaData rewrites the row of an object already in the CSV
+func CSVUpdateObjectMetaData(object *models.Object, bucketName string) {
+	defer metrics.ObserveMetadata("object_update", time.Now())
+	objectsMu.Lock()
+	defer objectsMu.Unlock()
+
+	metaFilePath := utils.DataPath(bucketName, "ObjectMetaData.csv")
+
+	data, err := os.ReadFile(metaFilePath)
+	if err != nil {
+		log.Printf("Error opening CSV file: %v", err)
+		return
+	}
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		log.Printf("Error reading CSV file: %v", err)
+		return
+	}
+
+	key := storedKey(object.ObjectKey, bucketName)
+	objectIndex := -1
+	for i, record := range records {
+		if i > 0 && record[0] == key {
+			objectIndex = i
+			break
+		}
+	}
+	if objectIndex == -1 {
+		log.Printf("Object not found in CSV: %s", object.ObjectKey)
+		return
+	}
+	records[objectIndex] = objectRecord(object, bucketName)
+
+	metaFile, err := os.OpenFile(metaFilePath, os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		log.Fatalf("Error opening CSV file for writing: %v", err)
+	}
+	defer metaFile.Close()
+
+	writer := csv.NewWriter(metaFile)
+	defer writer.Flush()
+
+	if err := writer.WriteAll(records); err != nil {
+		log.Fatalf("Error writing updated CSV records: %v", err)
+	}
+}
+
+// bucketRecord is the row of bucket in BucketMetaData.csv
+func bucketRecord(bucket *models.Bucket) []string {
+	return []string{
+		bucket.Name,
+		bucket.CreationTime.Format(time.RFC3339),
+		bucket.LastModified.Format(time.RFC3339),
+		bucket.Status,
+		bucket.Owner,
+		bucket.ACL,
+		strconv.FormatInt(bucket.Objects, 10),
+		strconv.FormatInt(bucket.Bytes, 10),
+		strconv.FormatInt(bucket.Quota.MaxObjects, 10),
+		strconv.FormatInt(bucket.Quota.MaxBytes, 10),
+		strconv.FormatInt(bucket.Quota.SoftMaxObjects, 10),
+		strconv.FormatInt(bucket.Quota.SoftMaxBytes, 10),
+	}
+}
+
 func CSVUpdateBucketMetaData(bucket *models.Bucket) {
-	metaFilePath := "data/BucketMetaData.csv"
+	defer metrics.ObserveMetadata("bucket_update", time.Now())
+
+	metaFilePath := utils.DataPath("BucketMetaData.csv")
 
 	metaFile, err := os.OpenFile(metaFilePath, os.O_RDWR|os.O_CREATE, 0o644)
 	if err != nil {
@@ -209,6 +342,7 @@ func CSVUpdateBucketMetaData(bucket *models.Bucket) {
 	defer metaFile.Close()
 
 	reader := csv.NewReader(metaFile)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
 		log.Fatal("Error reading CSV file: ", err)
@@ -229,10 +363,8 @@ func CSVUpdateBucketMetaData(bucket *models.Bucket) {
 		return
 	}
 
-	// renewing bucket
-	records[bucketIndex][1] = bucket.CreationTime.Format(time.RFC3339)
-	records[bucketIndex][2] = bucket.LastModified.Format(time.RFC3339)
-	records[bucketIndex][3] = bucket.Status
+	// renewing bucket, rows written by older versions get the new columns
+	records[bucketIndex] = bucketRecord(bucket)
 
 	// overwriting file with new name
 	metaFile, err = os.OpenFile(metaFilePath, os.O_WRONLY|os.O_TRUNC, 0o644)
@@ -250,3 +382,118 @@ func CSVUpdateBucketMetaData(bucket *models.Bucket) {
 
 	log.Printf("Bucket '%s' metadata updated successfully", bucket.Name)
 }
+
+// CSVLoadBuckets reads the bucket metadata written by CSVBucketWriter
+func CSVLoadBuckets() ([]models.Bucket, error) {
+	defer metrics.ObserveMetadata("bucket_load", time.Now())
+
+	records, err := readRecords(utils.DataPath("BucketMetaData.csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []models.Bucket
+	for _, record := range records {
+		if len(record) < 4 {
+			continue
+		}
+		created, _ := time.Parse(time.RFC3339, record[1])
+		modified, _ := time.Parse(time.RFC3339, record[2])
+		bucket := models.Bucket{
+			Name:         record[0],
+			CreationTime: created,
+			LastModified: modified,
+			Status:       record[3],
+		}
+		if len(record) > 4 {
+			bucket.Owner = record[4]
+		}
+		if len(record) > 5 {
+			bucket.ACL = record[5]
+		}
+		if len(record) > 11 {
+			bucket.Objects, _ = strconv.ParseInt(record[6], 10, 64)
+			bucket.Bytes, _ = strconv.ParseInt(record[7], 10, 64)
+			bucket.Quota.MaxObjects, _ = strconv.ParseInt(record[8], 10, 64)
+			bucket.Quota.MaxBytes, _ = strconv.ParseInt(record[9], 10, 64)
+			bucket.Quota.SoftMaxObjects, _ = strconv.ParseInt(record[10], 10, 64)
+			bucket.Quota.SoftMaxBytes, _ = strconv.ParseInt(record[11], 10, 64)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// CSVLoadObjects reads the object metadata of one bucket written by CSVObjectWriter
+func CSVLoadObjects(bucketName string) ([]models.Object, error) {
+	defer metrics.ObserveMetadata("object_load", time.Now())
+
+	metaFilePath := utils.DataPath(bucketName, "ObjectMetaData.csv")
+	data, err := os.ReadFile(metaFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	legacy := records[0][0] == "ObjectKey"
+
+	var objects []models.Object
+	for _, record := range records[1:] {
+		if len(record) < 4 {
+			continue
+		}
+		if legacy {
+			record[0] = legacyKey(record[0], bucketName)
+		}
+		size, _ := strconv.Atoi(record[1])
+		modified, _ := time.Parse(time.RFC3339, record[3])
+		object := models.Object{
+			ObjectKey:    utils.DataPath(bucketName, filepath.FromSlash(record[0])),
+			Size:         size,
+			ContentType:  record[2],
+			LastModified: modified,
+		}
+		if len(record) > 4 {
+			object.ACL = record[4]
+		}
+		if len(record) > 5 {
+			object.Encryption = record[5]
+		}
+		if len(record) > 6 {
+			object.CustomerKeyFingerprint = record[6]
+		}
+		if len(record) > 8 {
+			object.Compression = record[7]
+			object.StoredSize, _ = strconv.Atoi(record[8])
+		}
+		if len(record) > 9 && record[9] != "" {
+			object.Chunks = strings.Fields(record[9])
+		}
+		if len(record) > 11 {
+			object.Tags = record[10]
+			object.ReplicationStatus = record[11]
+		}
+		if len(record) > 14 {
+			object.LockMode = record[12]
+			object.RetainUntil, _ = time.Parse(time.RFC3339, record[13])
+			object.LegalHold = record[14] == "ON"
+		}
+		objects = append(objects, object)
+	}
+
+	if legacy {
+		records[0] = objectHeader
+		if err := writeRecords(metaFilePath, records); err != nil {
+			return nil, err
+		}
+		log.Printf("Object keys of bucket '%s' are now stored relative to the bucket", bucketName)
+	}
+	return objects, nil
+}
@@ -0,0 +1,122 @@
+package csv
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/csv"
+	"errors"
+	"os"
+	"time"
+)
+
+func usersFilePath() string {
+	return utils.DataPath("Users.csv")
+}
+
+func accessKeysFilePath() string {
+	return utils.DataPath("AccessKeys.csv")
+}
+
+// CSVLoadUsers reads the identity store, returning nothing if it doesn't exist yet
+func CSVLoadUsers() ([]models.User, error) {
+	records, err := readRecords(usersFilePath())
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	for _, record := range records {
+		if len(record) < 3 {
+			continue
+		}
+		created, _ := time.Parse(time.RFC3339, record[2])
+		users = append(users, models.User{
+			Name:         record[0],
+			Status:       record[1],
+			CreationTime: created,
+		})
+	}
+	return users, nil
+}
+
+func CSVSaveUsers(users []models.User) error {
+	records := [][]string{{"Name", "Status", "CreationTime"}}
+	for _, u := range users {
+		records = append(records, []string{u.Name, u.Status, u.CreationTime.Format(time.RFC3339)})
+	}
+	return writeRecords(usersFilePath(), records)
+}
+
+func CSVLoadAccessKeys() ([]models.AccessKey, error) {
+	records, err := readRecords(accessKeysFilePath())
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []models.AccessKey
+	for _, record := range records {
+		if len(record) < 5 {
+			continue
+		}
+		created, _ := time.Parse(time.RFC3339, record[4])
+		keys = append(keys, models.AccessKey{
+			AccessKeyID:     record[0],
+			SecretAccessKey: record[1],
+			UserName:        record[2],
+			Status:          record[3],
+			CreationTime:    created,
+		})
+	}
+	return keys, nil
+}
+
+// CSVSaveAccessKeys rewrites the key file; it holds secrets so it is only readable by the owner
+func CSVSaveAccessKeys(keys []models.AccessKey) error {
+	records := [][]string{{"AccessKeyId", "SecretAccessKey", "UserName", "Status", "CreationTime"}}
+	for _, k := range keys {
+		records = append(records, []string{k.AccessKeyID, k.SecretAccessKey, k.UserName, k.Status, k.CreationTime.Format(time.RFC3339)})
+	}
+	return writeRecords(accessKeysFilePath(), records)
+}
+
+// readRecords returns all rows after the header
+func readRecords(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[1:], nil
+}
+
+// writeRecords replaces path atomically so a crash never leaves a half-written file
+func writeRecords(path string, records [][]string) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
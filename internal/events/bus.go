@@ -0,0 +1,101 @@
+package events
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// the bus hands every published event to the listeners, synchronously, and
+// to the subscriptions, without ever blocking the publishing request
+var (
+	mu            sync.Mutex
+	listeners     []func(Event)
+	subscriptions = map[*Subscription]struct{}{}
+)
+
+// Listen registers fn to be called for every event; fn runs on the request
+// that caused the event, so it must be quick
+func Listen(fn func(Event)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Subscription receives the events of one bucket whose key starts with a prefix
+type Subscription struct {
+	Events  chan Event
+	bucket  string
+	prefix  string
+	dropped int
+}
+
+// Subscribe starts a subscription; buffer is how many events may be pending
+// before further events are dropped for this subscriber
+func Subscribe(bucket, prefix string, buffer int) *Subscription {
+	sub := &Subscription{Events: make(chan Event, buffer), bucket: bucket, prefix: prefix}
+
+	mu.Lock()
+	defer mu.Unlock()
+	subscriptions[sub] = struct{}{}
+	return sub
+}
+
+// Close ends the subscription; Events is closed so readers notice
+func (sub *Subscription) Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := subscriptions[sub]; ok {
+		delete(subscriptions, sub)
+		close(sub.Events)
+	}
+}
+
+// CloseBucket ends all subscriptions to a bucket that is being deleted
+func CloseBucket(bucket string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for sub := range subscriptions {
+		if sub.bucket == bucket {
+			delete(subscriptions, sub)
+			close(sub.Events)
+		}
+	}
+}
+
+// CloseAll ends every subscription, letting event streams finish on shutdown
+func CloseAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	for sub := range subscriptions {
+		delete(subscriptions, sub)
+		close(sub.Events)
+	}
+}
+
+// Publish delivers e to all listeners and matching subscriptions
+func Publish(e Event) {
+	mu.Lock()
+	current := listeners
+	mu.Unlock()
+	for _, fn := range current {
+		fn(e)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for sub := range subscriptions {
+		if sub.bucket != e.Bucket || !strings.HasPrefix(e.Key, sub.prefix) {
+			continue
+		}
+		select {
+		case sub.Events <- e:
+		default:
+			// a slow reader loses events instead of stalling uploads
+			sub.dropped++
+			if sub.dropped == 1 || sub.dropped%100 == 0 {
+				log.Printf("Event stream on bucket '%s' is behind, %d events dropped", sub.bucket, sub.dropped)
+			}
+		}
+	}
+}
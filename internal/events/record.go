@@ -0,0 +1,133 @@
+package events
+
+import (
+	"A3S/internal/auth"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Record is one event in the S3 event message format
+type Record struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      Identity          `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                S3Entity          `json:"s3"`
+}
+
+type Identity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type S3Entity struct {
+	SchemaVersion   string       `json:"s3SchemaVersion"`
+	ConfigurationID string       `json:"configurationId"`
+	Bucket          BucketEntity `json:"bucket"`
+	Object          ObjectEntity `json:"object"`
+}
+
+type BucketEntity struct {
+	Name          string   `json:"name"`
+	OwnerIdentity Identity `json:"ownerIdentity"`
+	ARN           string   `json:"arn"`
+}
+
+type ObjectEntity struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// Message is the S3 event message, as POSTed to webhooks
+type Message struct {
+	Records []Record `json:"Records"`
+}
+
+var sequence atomic.Uint64
+
+// Event is something that happened to an object
+type Event struct {
+	// Name is a full event type such as "s3:ObjectCreated:Put"
+	Name      string
+	Bucket    string
+	Owner     string
+	Key       string
+	Size      int64
+	Principal string
+	SourceIP  string
+	Time      time.Time
+	Sequencer string
+}
+
+// New describes an event caused by request r, which is nil for objects the
+// server writes itself
+func New(r *http.Request, name, bucket, owner, key string, size int64) Event {
+	principal, host := "", ""
+	if r != nil {
+		principal = auth.Caller(r)
+		var err error
+		if host, _, err = net.SplitHostPort(r.RemoteAddr); err != nil {
+			host = r.RemoteAddr
+		}
+	}
+
+	now := time.Now().UTC()
+	return Event{
+		Name:      name,
+		Bucket:    bucket,
+		Owner:     owner,
+		Key:       key,
+		Size:      size,
+		Principal: principal,
+		SourceIP:  host,
+		Time:      now,
+		Sequencer: fmt.Sprintf("%016X%04X", now.UnixNano(), sequence.Add(1)&0xFFFF),
+	}
+}
+
+// Message wraps the event in the S3 event message format; configurationID
+// names the notification configuration that matched it
+func (e Event) Message(configurationID string) Message {
+	return Message{Records: []Record{{
+		EventVersion:      "2.1",
+		EventSource:       "aws:s3",
+		AwsRegion:         auth.Region,
+		EventTime:         e.Time.Format("2006-01-02T15:04:05.000Z"),
+		EventName:         strings.TrimPrefix(e.Name, "s3:"),
+		UserIdentity:      Identity{PrincipalID: e.Principal},
+		RequestParameters: map[string]string{"sourceIPAddress": e.SourceIP},
+		ResponseElements:  map[string]string{},
+		S3: S3Entity{
+			SchemaVersion:   "1.0",
+			ConfigurationID: configurationID,
+			Bucket: BucketEntity{
+				Name:          e.Bucket,
+				OwnerIdentity: Identity{PrincipalID: e.Owner},
+				ARN:           "arn:aws:s3:::" + e.Bucket,
+			},
+			Object: ObjectEntity{
+				Key:       escapeKey(e.Key),
+				Size:      e.Size,
+				Sequencer: e.Sequencer,
+			},
+		},
+	}}}
+}
+
+// escapeKey URL encodes keys like S3 event messages do, keeping the slashes
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.QueryEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
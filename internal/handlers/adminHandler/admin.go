@@ -0,0 +1,29 @@
+package adminHandl
+
+import (
+	"A3S/internal/auth"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"net/http"
+)
+
+// requireAdmin rejects requests that were not signed with the root credentials
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if auth.Caller(r) != auth.RootUser {
+		s3err.Write(w, r, s3err.AccessDenied, "Admin credentials required")
+		return false
+	}
+	return true
+}
+
+func writeXML(w http.ResponseWriter, r *http.Request, v any, code int) {
+	xmlData, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	w.Write(xmlData)
+}
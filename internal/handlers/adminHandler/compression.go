@@ -0,0 +1,55 @@
+package adminHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"A3S/internal/utils"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// CompressionStatsHandler serves /_admin/compression: the compression ratio
+// achieved on every bucket holding compressed objects
+func CompressionStatsHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+		return
+	}
+
+	stats := models.CompressionStats{}
+	for _, b := range s.Buckets {
+		bucketStats := models.BucketCompressionStats{Name: b.Name}
+		prefix := utils.DataPath(b.Name) + string(filepath.Separator)
+		for _, o := range s.Object {
+			if o.Compression == "" || !strings.HasPrefix(o.ObjectKey, prefix) {
+				continue
+			}
+			bucketStats.Objects++
+			bucketStats.LogicalBytes += int64(o.Size)
+			bucketStats.StoredBytes += int64(o.StoredSize)
+		}
+		if bucketStats.Objects == 0 {
+			continue
+		}
+
+		// logical bytes per stored byte, like the ratio reported by zfs
+		bucketStats.Ratio = "0.00"
+		if bucketStats.StoredBytes > 0 {
+			bucketStats.Ratio = fmt.Sprintf("%.2f", float64(bucketStats.LogicalBytes)/float64(bucketStats.StoredBytes))
+		}
+		stats.Buckets = append(stats.Buckets, bucketStats)
+	}
+
+	writeXML(w, r, stats, http.StatusOK)
+}
+
+func CreateCompressionStatsHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		CompressionStatsHandler(w, r, s)
+	}
+}
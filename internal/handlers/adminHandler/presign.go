@@ -0,0 +1,97 @@
+package adminHandl
+
+import (
+	"A3S/internal/auth"
+	"A3S/internal/bucketname"
+	"A3S/internal/iam"
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func PresignHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		Presign(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+// Presign mints a presigned GET or PUT URL signed with an access key of the
+// IAM user named by ?user, so the URL can do no more than that user. The root
+// credentials are never used: whoever holds the URL would act as root.
+func Presign(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	userName := query.Get("user")
+	bucket := query.Get("bucket")
+	key := query.Get("key")
+
+	method := query.Get("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPut {
+		s3err.Write(w, r, s3err.InvalidArgument, "Only GET and PUT URLs can be presigned")
+		return
+	}
+
+	if e, err := bucketname.Validate(bucket); err != nil {
+		s3err.Write(w, r, e, fmt.Sprintf("Invalid bucket name: %v", err))
+		return
+	}
+	if key == "" {
+		s3err.Write(w, r, s3err.InvalidArgument, "Object key is required")
+		return
+	}
+	if userName == "" {
+		s3err.Write(w, r, s3err.InvalidArgument, "User is required")
+		return
+	}
+	signingKey, err := iam.SigningKey(s, userName)
+	if err != nil {
+		writeIAMError(w, r, err)
+		return
+	}
+
+	expires := time.Hour
+	if raw := query.Get("expires"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil {
+			s3err.Write(w, r, s3err.InvalidArgument, "expires must be a number of seconds")
+			return
+		}
+		expires = time.Duration(seconds) * time.Second
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	now := time.Now()
+	url, err := auth.PresignURL(method, scheme+"://"+r.Host, bucket, key, signingKey.AccessKeyID, signingKey.SecretAccessKey, expires, now)
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidArgument, err.Error())
+		return
+	}
+
+	writeXML(w, r, models.PresignedURL{
+		Method:  method,
+		URL:     url,
+		Expires: now.Add(expires).UTC(),
+	}, http.StatusOK)
+}
+
+func CreatePresignHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		PresignHandler(w, r, s)
+	}
+}
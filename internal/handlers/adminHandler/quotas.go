@@ -0,0 +1,90 @@
+package adminHandl
+
+import (
+	"A3S/internal/csv"
+	"A3S/internal/models"
+	"A3S/internal/quota"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// QuotasHandler serves /_admin/quotas and /_admin/quotas/{bucket}
+func QuotasHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name := r.PathValue("bucket")
+	if name == "" {
+		if r.Method != http.MethodGet {
+			s3err.Write(w, r, s3err.MethodNotAllowed, "")
+			return
+		}
+		list := models.BucketQuotaStatusList{}
+		for i := range s.Buckets {
+			list.Buckets = append(list.Buckets, quota.Status(&s.Buckets[i]))
+		}
+		writeXML(w, r, list, http.StatusOK)
+		return
+	}
+
+	var bucket *models.Bucket
+	for i := range s.Buckets {
+		if s.Buckets[i].Name == name {
+			bucket = &s.Buckets[i]
+			break
+		}
+	}
+	if bucket == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeXML(w, r, quota.Status(bucket), http.StatusOK)
+	case http.MethodPut:
+		PutBucketQuota(w, r, bucket)
+	case http.MethodDelete:
+		bucket.Quota = models.BucketQuota{}
+		csv.CSVUpdateBucketMetaData(bucket)
+		log.Printf("Quota of bucket '%s' removed", bucket.Name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+// PutBucketQuota replaces all limits of bucket with the BucketQuota document in the body
+func PutBucketQuota(w http.ResponseWriter, r *http.Request, bucket *models.Bucket) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading quota")
+		return
+	}
+
+	var q models.BucketQuota
+	if err := xml.Unmarshal(data, &q); err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed quota: %v", err))
+		return
+	}
+	if err := quota.Validate(q); err != nil {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid quota: %v", err))
+		return
+	}
+
+	bucket.Quota = q
+	csv.CSVUpdateBucketMetaData(bucket)
+	log.Printf("Quota of bucket '%s' set to %d objects, %d bytes", bucket.Name, q.MaxObjects, q.MaxBytes)
+	writeXML(w, r, quota.Status(bucket), http.StatusOK)
+}
+
+func CreateQuotasHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		QuotasHandler(w, r, s)
+	}
+}
@@ -0,0 +1,113 @@
+package adminHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/ratelimit"
+	"A3S/internal/s3err"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// RateLimitsHandler serves /_admin/ratelimits and /_admin/ratelimits/{bucket}
+func RateLimitsHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name := r.PathValue("bucket")
+	if name == "" {
+		if r.Method != http.MethodGet {
+			s3err.Write(w, r, s3err.MethodNotAllowed, "")
+			return
+		}
+		list := models.RateLimitList{Client: ratelimit.Client}
+		for _, b := range s.Buckets {
+			limit, err := ratelimit.Load(b.Name)
+			if err != nil {
+				log.Printf("Error reading rate limit of bucket '%s': %v", b.Name, err)
+				continue
+			}
+			if limit != nil {
+				limit.Bucket = b.Name
+				list.Buckets = append(list.Buckets, *limit)
+			}
+		}
+		writeXML(w, r, list, http.StatusOK)
+		return
+	}
+
+	found := false
+	for _, b := range s.Buckets {
+		if b.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		limit, err := ratelimit.Load(name)
+		if err != nil {
+			s3err.Write(w, r, s3err.InternalError, "Error reading rate limit")
+			return
+		}
+		if limit == nil {
+			s3err.Write(w, r, s3err.NoSuchRateLimit, "")
+			return
+		}
+		limit.Bucket = name
+		writeXML(w, r, limit, http.StatusOK)
+	case http.MethodPut:
+		PutBucketRateLimit(w, r, name)
+	case http.MethodDelete:
+		if err := ratelimit.Delete(name); err != nil {
+			s3err.Write(w, r, s3err.InternalError, "Error deleting rate limit")
+			return
+		}
+		log.Printf("Rate limit of bucket '%s' removed", name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+// PutBucketRateLimit replaces the rate limit of bucket with the RateLimit
+// document in the body; it applies from the next request on
+func PutBucketRateLimit(w http.ResponseWriter, r *http.Request, bucket string) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, ratelimit.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading rate limit")
+		return
+	}
+	if len(data) > ratelimit.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Rate limit is too large")
+		return
+	}
+
+	limit, err := ratelimit.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid rate limit: %v", err))
+		return
+	}
+	if err := ratelimit.Save(bucket, limit); err != nil {
+		log.Printf("Error saving rate limit of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving rate limit")
+		return
+	}
+
+	log.Printf("Rate limit of bucket '%s' set to %g requests/s, %d bytes/s", bucket, limit.RequestsPerSecond, limit.BytesPerSecond)
+	limit.Bucket = bucket
+	writeXML(w, r, limit, http.StatusOK)
+}
+
+func CreateRateLimitsHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RateLimitsHandler(w, r, s)
+	}
+}
@@ -0,0 +1,182 @@
+package adminHandl
+
+import (
+	"A3S/internal/iam"
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// UsersHandler serves /_admin/users and /_admin/users/{user}
+func UsersHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name := r.PathValue("user")
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		writeXML(w, r, models.UserList{Users: s.Users}, http.StatusOK)
+	case r.Method == http.MethodGet:
+		GetUser(w, r, s)
+	case r.Method == http.MethodPut && name != "":
+		CreateUser(w, r, s)
+	case r.Method == http.MethodDelete && name != "":
+		DeleteUser(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+// UserActionHandler serves /_admin/users/{user}/{action}
+func UserActionHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name := r.PathValue("user")
+	switch action := r.PathValue("action"); {
+	case action == "enable" && r.Method == http.MethodPost:
+		setUserStatus(w, r, s, name, iam.StatusActive)
+	case action == "disable" && r.Method == http.MethodPost:
+		setUserStatus(w, r, s, name, iam.StatusInactive)
+	case action == "keys" && r.Method == http.MethodGet:
+		if iam.FindUser(s, name) == nil {
+			s3err.Write(w, r, s3err.NoSuchEntity, "User not found")
+			return
+		}
+		writeXML(w, r, models.AccessKeyList{AccessKeys: iam.UserAccessKeys(s, name)}, http.StatusOK)
+	case action == "keys" && r.Method == http.MethodPost:
+		CreateAccessKey(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.InvalidArgument, "Unknown user action")
+	}
+}
+
+// KeyActionHandler serves /_admin/keys/{key} and /_admin/keys/{key}/{action}
+func KeyActionHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	keyID := r.PathValue("key")
+	switch action := r.PathValue("action"); {
+	case action == "" && r.Method == http.MethodDelete:
+		if err := iam.DeleteAccessKey(s, keyID); err != nil {
+			writeIAMError(w, r, err)
+			return
+		}
+		log.Printf("Access key '%s' deleted", keyID)
+		w.WriteHeader(http.StatusNoContent)
+	case action == "enable" && r.Method == http.MethodPost:
+		setKeyStatus(w, r, s, keyID, iam.StatusActive)
+	case action == "disable" && r.Method == http.MethodPost:
+		setKeyStatus(w, r, s, keyID, iam.StatusInactive)
+	case action == "rotate" && r.Method == http.MethodPost:
+		key, err := iam.RotateAccessKey(s, keyID)
+		if err != nil {
+			writeIAMError(w, r, err)
+			return
+		}
+		log.Printf("Access key '%s' rotated to '%s'", keyID, key.AccessKeyID)
+		writeXML(w, r, key, http.StatusOK)
+	default:
+		s3err.Write(w, r, s3err.InvalidArgument, "Unknown access key action")
+	}
+}
+
+func GetUser(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	user := iam.FindUser(s, r.PathValue("user"))
+	if user == nil {
+		s3err.Write(w, r, s3err.NoSuchEntity, "User not found")
+		return
+	}
+	writeXML(w, r, user, http.StatusOK)
+}
+
+func CreateUser(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	user, err := iam.CreateUser(s, r.PathValue("user"))
+	if err != nil {
+		writeIAMError(w, r, err)
+		return
+	}
+	log.Printf("User '%s' created", user.Name)
+	writeXML(w, r, user, http.StatusOK)
+}
+
+func DeleteUser(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	name := r.PathValue("user")
+	if err := iam.DeleteUser(s, name); err != nil {
+		writeIAMError(w, r, err)
+		return
+	}
+	log.Printf("User '%s' deleted", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateAccessKey returns the new secret; it cannot be retrieved again later
+func CreateAccessKey(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	key, err := iam.CreateAccessKey(s, r.PathValue("user"))
+	if err != nil {
+		writeIAMError(w, r, err)
+		return
+	}
+	log.Printf("Access key '%s' created for user '%s'", key.AccessKeyID, key.UserName)
+	writeXML(w, r, key, http.StatusOK)
+}
+
+func setUserStatus(w http.ResponseWriter, r *http.Request, s *models.Storage, name, status string) {
+	user, err := iam.SetUserStatus(s, name, status)
+	if err != nil {
+		writeIAMError(w, r, err)
+		return
+	}
+	log.Printf("User '%s' is now %s", name, status)
+	writeXML(w, r, user, http.StatusOK)
+}
+
+func setKeyStatus(w http.ResponseWriter, r *http.Request, s *models.Storage, keyID, status string) {
+	key, err := iam.SetAccessKeyStatus(s, keyID, status)
+	if err != nil {
+		writeIAMError(w, r, err)
+		return
+	}
+	log.Printf("Access key '%s' is now %s", keyID, status)
+	masked := *key
+	masked.SecretAccessKey = ""
+	writeXML(w, r, masked, http.StatusOK)
+}
+
+func writeIAMError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, iam.ErrUserNotFound), errors.Is(err, iam.ErrKeyNotFound):
+		s3err.Write(w, r, s3err.NoSuchEntity, err.Error())
+	case errors.Is(err, iam.ErrUserExists):
+		s3err.Write(w, r, s3err.EntityAlreadyExists, err.Error())
+	case errors.Is(err, iam.ErrInvalidName), errors.Is(err, iam.ErrNoActiveKey):
+		s3err.Write(w, r, s3err.InvalidArgument, err.Error())
+	default:
+		log.Printf("Identity store error: %v", err)
+		s3err.Write(w, r, s3err.InternalError, "Failed to update identity store")
+	}
+}
+
+func CreateUsersHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		UsersHandler(w, r, s)
+	}
+}
+
+func CreateUserActionHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		UserActionHandler(w, r, s)
+	}
+}
+
+func CreateKeyActionHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		KeyActionHandler(w, r, s)
+	}
+}
@@ -0,0 +1,64 @@
+package bucketHandl
+
+import (
+	"A3S/internal/acl"
+	"A3S/internal/csv"
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func GetBucketACL(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	bucket := findBucket(s, bucketName)
+	if bucket == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetBucketAcl", bucketName, "") {
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(acl.Policy(bucket.ACL, bucket.Owner), "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+// PutBucketACL only accepts canned ACLs through the x-amz-acl header
+func PutBucketACL(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	bucket := findBucket(s, bucketName)
+	if bucket == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketAcl", bucketName, "") {
+		return
+	}
+
+	cannedACL := r.Header.Get("x-amz-acl")
+	if cannedACL == "" {
+		s3err.Write(w, r, s3err.NotImplemented, "Only canned ACLs in the x-amz-acl header are supported")
+		return
+	}
+	if !acl.Valid(cannedACL) {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid canned ACL '%s'", cannedACL))
+		return
+	}
+
+	bucket.ACL = cannedACL
+	csv.CSVUpdateBucketMetaData(bucket)
+	log.Printf("Bucket '%s' ACL set to %s", bucketName, cannedACL)
+
+	w.WriteHeader(http.StatusOK)
+}
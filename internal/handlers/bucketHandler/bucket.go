@@ -1,66 +1,274 @@
 package bucketHandl
 
 import (
+	"A3S/internal/acl"
+	"A3S/internal/auth"
+	"A3S/internal/blob"
+	"A3S/internal/bucketname"
+	"A3S/internal/cors"
 	"A3S/internal/csv"
+	"A3S/internal/events"
 	"A3S/internal/models"
+	"A3S/internal/objectlock"
+	"A3S/internal/policy"
+	"A3S/internal/ratelimit"
+	"A3S/internal/s3err"
 	"A3S/internal/utils"
-	"errors"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strings"
 	"time"
 )
 
 func BucketHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if r.Method == http.MethodOptions {
+		cors.Preflight(w, r)
+		return
+	}
+	cors.ApplyHeaders(w, r)
+
+	query := r.URL.Query()
+	switch {
+	case query.Has("policy"):
+		BucketPolicyHandler(w, r, s)
+		return
+	case query.Has("acl"):
+		BucketACLHandler(w, r, s)
+		return
+	case query.Has("cors"):
+		BucketCORSHandler(w, r, s)
+		return
+	case query.Has("website"):
+		BucketWebsiteHandler(w, r, s)
+		return
+	case query.Has("encryption"):
+		BucketEncryptionHandler(w, r, s)
+		return
+	case query.Has("compression"):
+		BucketCompressionHandler(w, r, s)
+		return
+	case query.Has("notification"):
+		BucketNotificationHandler(w, r, s)
+		return
+	case query.Has("replication"):
+		BucketReplicationHandler(w, r, s)
+		return
+	case query.Has("object-lock"):
+		BucketObjectLockHandler(w, r, s)
+		return
+	case query.Has("logging"):
+		BucketLoggingHandler(w, r, s)
+		return
+	case query.Has("events"):
+		if r.Method != http.MethodGet {
+			s3err.Write(w, r, s3err.MethodNotAllowed, "")
+			return
+		}
+		GetBucketEvents(w, r, s)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodPut:
 		PutBucket(w, r, s)
 	case http.MethodDelete:
 		DeleteBucket(w, r, s)
 	default:
-		utils.WriteXMLError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketPolicyHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketPolicy(w, r, s)
+	case http.MethodGet:
+		GetBucketPolicy(w, r, s)
+	case http.MethodDelete:
+		DeleteBucketPolicy(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketACLHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketACL(w, r, s)
+	case http.MethodGet:
+		GetBucketACL(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketCORSHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketCORS(w, r, s)
+	case http.MethodGet:
+		GetBucketCORS(w, r, s)
+	case http.MethodDelete:
+		DeleteBucketCORS(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketWebsiteHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketWebsite(w, r, s)
+	case http.MethodGet:
+		GetBucketWebsite(w, r, s)
+	case http.MethodDelete:
+		DeleteBucketWebsite(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketEncryptionHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketEncryption(w, r, s)
+	case http.MethodGet:
+		GetBucketEncryption(w, r, s)
+	case http.MethodDelete:
+		DeleteBucketEncryption(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketCompressionHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketCompression(w, r, s)
+	case http.MethodGet:
+		GetBucketCompression(w, r, s)
+	case http.MethodDelete:
+		DeleteBucketCompression(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketNotificationHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketNotification(w, r, s)
+	case http.MethodGet:
+		GetBucketNotification(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketReplicationHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketReplication(w, r, s)
+	case http.MethodGet:
+		GetBucketReplication(w, r, s)
+	case http.MethodDelete:
+		DeleteBucketReplication(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketObjectLockHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketObjectLock(w, r, s)
+	case http.MethodGet:
+		GetBucketObjectLock(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func BucketLoggingHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutBucketLogging(w, r, s)
+	case http.MethodGet:
+		GetBucketLogging(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
 	}
 }
 
 func PutBucket(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	bucket := r.PathValue("bucket")
 
-	statusCode, err := ValidateBucketName(bucket)
-	if err != nil {
-		utils.WriteXMLError(w, fmt.Sprintf("Invalid bucket name: %v", err), statusCode)
+	if e, err := bucketname.Validate(bucket); err != nil {
+		s3err.Write(w, r, e, fmt.Sprintf("Invalid bucket name: %v", err))
 		return
 	}
 
-	dataDir := "data"
+	// a bucket without an owner is open to everyone, so anonymous callers
+	// may only create buckets on a server without any credentials
+	if auth.Caller(r) == "" && (*utils.AccessKey != "" || len(s.AccessKeys) > 0) {
+		log.Printf("Access denied: s3:CreateBucket by anonymous caller on %s", bucket)
+		s3err.Write(w, r, s3err.AccessDenied, "")
+		return
+	}
 
-	if _, err := os.Stat(*utils.Dir); os.IsNotExist(err) {
-		data := "data"
-		os.Mkdir(data, 0o777)
+	cannedACL := r.Header.Get("x-amz-acl")
+	if cannedACL == "" {
+		cannedACL = acl.Private
+	}
+	if !acl.Valid(cannedACL) {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid canned ACL '%s'", cannedACL))
+		return
+	}
+
+	objectLock := strings.ToLower(r.Header.Get("x-amz-bucket-object-lock-enabled"))
+	if objectLock != "" && objectLock != "true" && objectLock != "false" {
+		s3err.Write(w, r, s3err.InvalidArgument, "x-amz-bucket-object-lock-enabled must be true or false")
+		return
 	}
 
-	BucketDir := filepath.Join(dataDir, bucket)
+	BucketDir := utils.DataPath(bucket)
 
 	if _, err := os.Stat(BucketDir); !os.IsNotExist(err) {
-		utils.WriteXMLError(w, "Directory with this name already exists", http.StatusConflict)
+		e := s3err.BucketAlreadyExists
+		for _, b := range s.Buckets {
+			if b.Name == bucket && b.Owner == auth.Caller(r) {
+				e = s3err.BucketAlreadyOwnedByYou
+			}
+		}
+		s3err.Write(w, r, e, "")
 		return
 	}
 
-	err = os.Mkdir(BucketDir, 0o755)
+	err := os.Mkdir(BucketDir, 0o755)
 	if err != nil {
-		utils.WriteXMLError(w, "Error creating directory", http.StatusInternalServerError)
+		s3err.Write(w, r, s3err.InternalError, "Error creating directory")
 		return
 	}
 
+	if objectLock == "true" {
+		if err := objectlock.Enable(bucket); err != nil {
+			os.RemoveAll(BucketDir)
+			s3err.Write(w, r, s3err.InternalError, "Error enabling object lock")
+			return
+		}
+	}
+
 	// creating new bucket
 	newBucket := &models.Bucket{
 		Name:         bucket,
 		CreationTime: time.Now(),
 		LastModified: time.Now(),
 		Status:       "Marked for deletion",
+		Owner:        auth.Caller(r),
+		ACL:          cannedACL,
 	}
 
 	// adding bucket to buckets array
@@ -73,7 +281,8 @@ func PutBucket(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 
 	csv.CSVBucketWriter(newBucket)
 
-	utils.WriteXMLError(w, fmt.Sprintf("Bucket '%s' created successfully!", bucket), http.StatusOK)
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
 }
 
 func DeleteBucket(w http.ResponseWriter, r *http.Request, s *models.Storage) {
@@ -93,33 +302,69 @@ func DeleteBucket(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	}
 
 	if bucket == nil {
-		utils.WriteXMLError(w, "Bucket not found", http.StatusNotFound)
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+
+	if !policy.Authorize(w, r, s, "s3:DeleteBucket", bucketName, "") {
 		return
 	}
 
 	if bucket.Status == "Activ" {
 		log.Printf("Bucket '%s' is active and cannot be deleted", bucketName)
-		utils.WriteXMLError(w, "Bucket has object and cannot be deleted", http.StatusForbidden)
+		s3err.Write(w, r, s3err.BucketNotEmpty, "")
 		return
 	}
 
 	if bucket.Status != "Marked for deletion" {
 		log.Printf("Bucket '%s' is not marked for deletion", bucketName)
-		utils.WriteXMLError(w, "Bucket is not empty", http.StatusConflict)
+		s3err.Write(w, r, s3err.BucketNotEmpty, "")
 		return
 	}
 
-	err := os.RemoveAll(filepath.Join("data", bucketName))
+	// locked objects would go down with the bucket
+	prefix := utils.DataPath(bucketName) + string(filepath.Separator)
+	bypass := objectlock.Bypass(r, bucketName, "")
+	for i := range s.Object {
+		if !strings.HasPrefix(s.Object[i].ObjectKey, prefix) {
+			continue
+		}
+		if err := objectlock.CheckRemoval(&s.Object[i], bypass); err != nil {
+			key := filepath.ToSlash(strings.TrimPrefix(s.Object[i].ObjectKey, prefix))
+			s3err.Write(w, r, s3err.BucketNotEmpty, fmt.Sprintf("Bucket contains locked objects: '%s': %v", key, err))
+			return
+		}
+	}
+
+	err := os.RemoveAll(utils.DataPath(bucketName))
 	if err != nil {
-		utils.WriteXMLError(w, "Failed to delete bucket directory", http.StatusInternalServerError)
+		s3err.Write(w, r, s3err.InternalError, "Failed to delete bucket directory")
 		return
 	}
 
-	// deleteing bucket from storage
+	// objects still listed in the bucket give up their chunks
+	remaining := s.Object[:0]
+	for i := range s.Object {
+		if !strings.HasPrefix(s.Object[i].ObjectKey, prefix) {
+			remaining = append(remaining, s.Object[i])
+			continue
+		}
+		if err := blob.Release(&s.Object[i]); err != nil {
+			log.Printf("Error releasing data of object '%s': %v", s.Object[i].ObjectKey, err)
+		}
+		key := strings.TrimPrefix(s.Object[i].ObjectKey, prefix)
+		events.Publish(events.New(r, "s3:ObjectRemoved:Delete", bucketName, bucket.Owner, filepath.ToSlash(key), 0))
+	}
+	s.Object = remaining
+	events.CloseBucket(bucketName)
+	ratelimit.Forget(bucketName)
+
+	// deleteing bucket from storage; bucket points into the slice, so it
+	// names the next bucket once this one is cut out
 	s.Buckets = append(s.Buckets[:bucketIndex], s.Buckets[bucketIndex+1:]...)
 
-	csv.CSVDBucketDelete(bucket)
-	utils.WriteXMLError(w, fmt.Sprintf("Bucket '%s' deleted successfully", bucketName), http.StatusOK)
+	csv.CSVDBucketDelete(bucketName)
+	w.WriteHeader(http.StatusNoContent)
 	log.Printf("Bucket '%s' deleted successfully", bucketName)
 }
 
@@ -129,24 +374,6 @@ func CreateBucketHandler(s *models.Storage) http.HandlerFunc {
 	}
 }
 
-func ValidateBucketName(bucketName string) (int, error) {
-	if len(bucketName) < 3 || len(bucketName) > 63 {
-		return http.StatusBadRequest, errors.New("bucket name must be between 3 and 63 characters")
-	}
-
-	validBucketName := regexp.MustCompile(`^[a-z0-9]([a-z0-9\-\.]{1,61}[a-z0-9])?$`)
-
-	if !validBucketName.MatchString(bucketName) {
-		return http.StatusBadRequest, errors.New("bucket name must only contain lowercase letters, numbers, hyphens, and periods")
-	}
-
-	if net.ParseIP(bucketName) != nil {
-		return http.StatusBadRequest, errors.New("bucket name must not be formatted as an IP address")
-	}
-
-	return 0, nil
-}
-
 func UpdateBucketStatus(bucket *models.Bucket, s *models.Storage) {
 	isEmpty := true
 
@@ -0,0 +1,101 @@
+package bucketHandl
+
+import (
+	"A3S/internal/compress"
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// PutBucketCompression only affects objects written afterwards; existing
+// objects keep the layout they were stored with
+func PutBucketCompression(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutCompressionConfiguration", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, compress.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading compression configuration")
+		return
+	}
+	if len(data) > compress.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Compression configuration is too large")
+		return
+	}
+
+	config, err := compress.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed compression configuration: %v", err))
+		return
+	}
+
+	if err := compress.Save(bucket, config); err != nil {
+		log.Printf("Error saving compression configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving compression configuration")
+		return
+	}
+
+	log.Printf("Compression of bucket '%s' set to %s", bucket, config.Algorithm)
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetBucketCompression(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetCompressionConfiguration", bucket, "") {
+		return
+	}
+
+	config, err := compress.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading compression configuration")
+		return
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.NoSuchCompressionConfiguration, "")
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+func DeleteBucketCompression(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutCompressionConfiguration", bucket, "") {
+		return
+	}
+
+	if err := compress.Delete(bucket); err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error deleting compression configuration")
+		return
+	}
+
+	log.Printf("Compression configuration of bucket '%s' deleted", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package bucketHandl
+
+import (
+	"A3S/internal/cors"
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+func PutBucketCORS(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketCORS", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, cors.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading CORS configuration")
+		return
+	}
+	if len(data) > cors.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "CORS configuration is too large")
+		return
+	}
+
+	config, err := cors.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed CORS configuration: %v", err))
+		return
+	}
+
+	if err := cors.Save(bucket, config); err != nil {
+		log.Printf("Error saving CORS configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving CORS configuration")
+		return
+	}
+
+	log.Printf("CORS configuration of bucket '%s' updated", bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetBucketCORS(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetBucketCORS", bucket, "") {
+		return
+	}
+
+	config, err := cors.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading CORS configuration")
+		return
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.NoSuchCORSConfiguration, "")
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+func DeleteBucketCORS(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketCORS", bucket, "") {
+		return
+	}
+
+	if err := cors.Delete(bucket); err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error deleting CORS configuration")
+		return
+	}
+
+	log.Printf("CORS configuration of bucket '%s' deleted", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
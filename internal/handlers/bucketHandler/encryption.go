@@ -0,0 +1,103 @@
+package bucketHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"A3S/internal/sse"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+func PutBucketEncryption(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutEncryptionConfiguration", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, sse.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading encryption configuration")
+		return
+	}
+	if len(data) > sse.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Encryption configuration is too large")
+		return
+	}
+
+	config, err := sse.ParseConfig(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed encryption configuration: %v", err))
+		return
+	}
+	if !sse.Enabled() {
+		s3err.Write(w, r, s3err.InvalidRequest, sse.ErrNoMasterKey.Error())
+		return
+	}
+
+	if err := sse.SaveBucketDefault(bucket, config); err != nil {
+		log.Printf("Error saving encryption configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving encryption configuration")
+		return
+	}
+
+	log.Printf("Default encryption of bucket '%s' updated", bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetBucketEncryption(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetEncryptionConfiguration", bucket, "") {
+		return
+	}
+
+	config, err := sse.LoadBucketDefault(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading encryption configuration")
+		return
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.ServerSideEncryptionConfigNotFound, "")
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+func DeleteBucketEncryption(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutEncryptionConfiguration", bucket, "") {
+		return
+	}
+
+	if err := sse.DeleteBucketDefault(bucket); err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error deleting encryption configuration")
+		return
+	}
+
+	log.Printf("Default encryption of bucket '%s' deleted", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package bucketHandl
+
+import (
+	"A3S/internal/events"
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"A3S/internal/storagelock"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// eventBuffer is how many events a slow stream may fall behind before it loses some
+	eventBuffer = 256
+	// keepAliveInterval keeps proxies from closing idle streams
+	keepAliveInterval = 15 * time.Second
+)
+
+// GetBucketEvents streams the object events of a bucket as Server-Sent Events
+// until the client goes away or the bucket is deleted; ?prefix limits the
+// stream to keys starting with it
+func GetBucketEvents(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:ListenBucketNotification", bucket, "") {
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	sub := events.Subscribe(bucket, prefix, eventBuffer)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Event stream on bucket '%s' cannot be flushed: %v", bucket, err)
+		return
+	}
+	log.Printf("Event stream opened on bucket '%s' with prefix '%s'", bucket, prefix)
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	// the stream lasts as long as the client wants, other requests go on meanwhile
+	storagelock.Unlocked(r, func() {
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e.Message(""))
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.Sequencer, strings.TrimPrefix(e.Name, "s3:"), data)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package bucketHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"A3S/internal/serverlog"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// PutBucketLogging replaces the logging status; the caller must be allowed to
+// write to the target bucket, where the log objects will appear
+func PutBucketLogging(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketLogging", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, serverlog.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading logging status")
+		return
+	}
+	if len(data) > serverlog.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Logging status is too large")
+		return
+	}
+
+	status, err := serverlog.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed logging status: %v", err))
+		return
+	}
+
+	if target := status.LoggingEnabled; target != nil {
+		if findBucket(s, target.TargetBucket) == nil {
+			s3err.Write(w, r, s3err.InvalidTargetBucketForLogging, fmt.Sprintf("Target bucket '%s' not found", target.TargetBucket))
+			return
+		}
+		if !policy.Authorize(w, r, s, "s3:PutObject", target.TargetBucket, target.TargetPrefix) {
+			return
+		}
+	}
+
+	if err := serverlog.Save(bucket, status); err != nil {
+		log.Printf("Error saving logging status of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving logging status")
+		return
+	}
+
+	if status.LoggingEnabled == nil {
+		log.Printf("Access logging of bucket '%s' disabled", bucket)
+	} else {
+		log.Printf("Access log of bucket '%s' goes to '%s/%s'", bucket, status.LoggingEnabled.TargetBucket, status.LoggingEnabled.TargetPrefix)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketLogging answers an empty BucketLoggingStatus when logging is off, as S3 does
+func GetBucketLogging(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetBucketLogging", bucket, "") {
+		return
+	}
+
+	target, err := serverlog.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading logging status")
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(models.BucketLoggingStatus{LoggingEnabled: target}, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
@@ -0,0 +1,82 @@
+package bucketHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/notify"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// PutBucketNotification replaces the whole configuration; an empty
+// NotificationConfiguration turns notifications off, as in S3
+func PutBucketNotification(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketNotification", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, notify.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading notification configuration")
+		return
+	}
+	if len(data) > notify.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Notification configuration is too large")
+		return
+	}
+
+	config, err := notify.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed notification configuration: %v", err))
+		return
+	}
+
+	if err := notify.Save(bucket, config); err != nil {
+		log.Printf("Error saving notification configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving notification configuration")
+		return
+	}
+
+	log.Printf("Notification configuration of bucket '%s' updated with %d webhooks", bucket, len(config.Webhooks))
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetBucketNotification(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetBucketNotification", bucket, "") {
+		return
+	}
+
+	config, err := notify.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading notification configuration")
+		return
+	}
+	// S3 answers a bucket without notifications with an empty configuration
+	if config == nil {
+		config = &models.NotificationConfiguration{}
+	}
+
+	xmlData, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
@@ -0,0 +1,82 @@
+package bucketHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/objectlock"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// PutBucketObjectLock enables object lock or changes the default retention;
+// the default only applies to objects written afterwards
+func PutBucketObjectLock(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketObjectLockConfiguration", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, objectlock.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading object lock configuration")
+		return
+	}
+	if len(data) > objectlock.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Object lock configuration is too large")
+		return
+	}
+
+	config, err := objectlock.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed object lock configuration: %v", err))
+		return
+	}
+
+	if err := objectlock.Save(bucket, config); err != nil {
+		log.Printf("Error saving object lock configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving object lock configuration")
+		return
+	}
+
+	log.Printf("Object lock of bucket '%s' enabled", bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetBucketObjectLock(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetBucketObjectLockConfiguration", bucket, "") {
+		return
+	}
+
+	config, err := objectlock.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading object lock configuration")
+		return
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.ObjectLockConfigurationNotFound, "")
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
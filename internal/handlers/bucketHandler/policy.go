@@ -0,0 +1,95 @@
+package bucketHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+func PutBucketPolicy(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketPolicy", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, policy.MaxPolicySize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading policy")
+		return
+	}
+
+	if _, err := policy.Parse(data, bucket); err != nil {
+		s3err.Write(w, r, s3err.MalformedPolicy, fmt.Sprintf("Malformed policy: %v", err))
+		return
+	}
+
+	if err := policy.Save(bucket, data); err != nil {
+		log.Printf("Error saving policy of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving policy")
+		return
+	}
+
+	log.Printf("Policy of bucket '%s' updated", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetBucketPolicy(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetBucketPolicy", bucket, "") {
+		return
+	}
+
+	data, err := policy.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading policy")
+		return
+	}
+	if data == nil {
+		s3err.Write(w, r, s3err.NoSuchBucketPolicy, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func DeleteBucketPolicy(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:DeleteBucketPolicy", bucket, "") {
+		return
+	}
+
+	if err := policy.Delete(bucket); err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error deleting policy")
+		return
+	}
+
+	log.Printf("Policy of bucket '%s' deleted", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func findBucket(s *models.Storage, name string) *models.Bucket {
+	for i := range s.Buckets {
+		if s.Buckets[i].Name == name {
+			return &s.Buckets[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package bucketHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/replication"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// PutBucketReplication only affects objects written afterwards; existing
+// objects are not copied
+func PutBucketReplication(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutReplicationConfiguration", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, replication.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading replication configuration")
+		return
+	}
+	if len(data) > replication.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Replication configuration is too large")
+		return
+	}
+
+	config, err := replication.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed replication configuration: %v", err))
+		return
+	}
+
+	if err := replication.Save(bucket, config); err != nil {
+		log.Printf("Error saving replication configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving replication configuration")
+		return
+	}
+
+	log.Printf("Replication configuration of bucket '%s' set with %d rules", bucket, len(config.Rules))
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketReplication leaves out the secret keys of the destinations
+func GetBucketReplication(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetReplicationConfiguration", bucket, "") {
+		return
+	}
+
+	config, err := replication.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading replication configuration")
+		return
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.ReplicationConfigurationNotFound, "")
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(replication.Redacted(config), "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+func DeleteBucketReplication(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutReplicationConfiguration", bucket, "") {
+		return
+	}
+
+	if err := replication.Delete(bucket); err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error deleting replication configuration")
+		return
+	}
+
+	log.Printf("Replication configuration of bucket '%s' deleted", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
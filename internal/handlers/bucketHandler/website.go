@@ -0,0 +1,99 @@
+package bucketHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"A3S/internal/website"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+func PutBucketWebsite(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutBucketWebsite", bucket, "") {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, website.MaxConfigSize+1))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading website configuration")
+		return
+	}
+	if len(data) > website.MaxConfigSize {
+		s3err.Write(w, r, s3err.MaxMessageLengthExceeded, "Website configuration is too large")
+		return
+	}
+
+	config, err := website.Parse(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed website configuration: %v", err))
+		return
+	}
+
+	if err := website.Save(bucket, config); err != nil {
+		log.Printf("Error saving website configuration of bucket '%s': %v", bucket, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving website configuration")
+		return
+	}
+
+	log.Printf("Website configuration of bucket '%s' updated", bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetBucketWebsite(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetBucketWebsite", bucket, "") {
+		return
+	}
+
+	config, err := website.Load(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading website configuration")
+		return
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.NoSuchWebsiteConfiguration, "")
+		return
+	}
+
+	xmlData, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+func DeleteBucketWebsite(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucket := r.PathValue("bucket")
+	if findBucket(s, bucket) == nil {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:DeleteBucketWebsite", bucket, "") {
+		return
+	}
+
+	if err := website.Delete(bucket); err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error deleting website configuration")
+		return
+	}
+
+	log.Printf("Website configuration of bucket '%s' deleted", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
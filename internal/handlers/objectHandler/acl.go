@@ -0,0 +1,96 @@
+package objectHandl
+
+import (
+	"A3S/internal/acl"
+	"A3S/internal/csv"
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func GetObjectACL(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	objectKey := r.PathValue("object")
+
+	bucket, object := findObject(s, bucketName, objectKey)
+	if bucket == nil || object == nil {
+		s3err.Write(w, r, s3err.NoSuchKey, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetObjectAcl", bucketName, objectKey) {
+		return
+	}
+
+	cannedACL := object.ACL
+	if cannedACL == "" {
+		cannedACL = bucket.ACL
+	}
+
+	xmlData, err := xml.MarshalIndent(acl.Policy(cannedACL, bucket.Owner), "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+// PutObjectACL only accepts canned ACLs through the x-amz-acl header
+func PutObjectACL(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	objectKey := r.PathValue("object")
+
+	bucket, object := findObject(s, bucketName, objectKey)
+	if bucket == nil || object == nil {
+		s3err.Write(w, r, s3err.NoSuchKey, "")
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutObjectAcl", bucketName, objectKey) {
+		return
+	}
+
+	cannedACL := r.Header.Get("x-amz-acl")
+	if cannedACL == "" {
+		s3err.Write(w, r, s3err.NotImplemented, "Only canned ACLs in the x-amz-acl header are supported")
+		return
+	}
+	if !acl.Valid(cannedACL) {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid canned ACL '%s'", cannedACL))
+		return
+	}
+
+	object.ACL = cannedACL
+	csv.CSVUpdateObjectMetaData(object, bucketName)
+	log.Printf("Object '%s' ACL set to %s", objectKey, cannedACL)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// findObject looks up the bucket and the in-memory metadata of one of its objects
+func findObject(s *models.Storage, bucketName, objectKey string) (*models.Bucket, *models.Object) {
+	var bucket *models.Bucket
+	for i := range s.Buckets {
+		if s.Buckets[i].Name == bucketName {
+			bucket = &s.Buckets[i]
+			break
+		}
+	}
+	if bucket == nil {
+		return nil, nil
+	}
+
+	objectPath := utils.DataPath(bucketName, objectKey)
+	for i := range s.Object {
+		if s.Object[i].ObjectKey == objectPath {
+			return bucket, &s.Object[i]
+		}
+	}
+	return bucket, nil
+}
@@ -0,0 +1,195 @@
+package objectHandl
+
+import (
+	"A3S/internal/acl"
+	"A3S/internal/blob"
+	"A3S/internal/compress"
+	"A3S/internal/csv"
+	"A3S/internal/models"
+	"A3S/internal/objectkey"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"A3S/internal/sse"
+	"A3S/internal/storagelock"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// parseCopySource splits x-amz-copy-source, "/bucket/key" or "bucket/key", into its parts
+func parseCopySource(header string) (string, string, error) {
+	source, _, _ := strings.Cut(header, "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return "", "", err
+	}
+	bucket, key, found := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !found || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("copy source must be of the form /bucket/key")
+	}
+	return bucket, key, nil
+}
+
+// CopyObject is a PUT with x-amz-copy-source: the object is decrypted with the
+// source's key and stored again under the destination's encryption settings,
+// which also makes it the way to rotate a customer-provided key
+func CopyObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	object := r.PathValue("object")
+	bucket := r.PathValue("bucket")
+
+	if e, err := objectkey.Validate(object); err != nil {
+		s3err.Write(w, r, e, fmt.Sprintf("Invalid object key: %v", err))
+		return
+	}
+
+	bucketDir := utils.DataPath(bucket)
+	objectPath := filepath.Join(bucketDir, object)
+
+	if _, err := os.Stat(bucketDir); os.IsNotExist(err) {
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+
+	sourceBucket, sourceKey, err := parseCopySource(r.Header.Get("x-amz-copy-source"))
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid copy source: %v", err))
+		return
+	}
+	_, sourceObject := findObject(s, sourceBucket, sourceKey)
+	if sourceObject == nil {
+		s3err.Write(w, r, s3err.NoSuchKey, "")
+		return
+	}
+	// the slice may be reshuffled below, so keep a copy
+	source := *sourceObject
+
+	if !policy.Authorize(w, r, s, "s3:GetObject", sourceBucket, sourceKey) {
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutObject", bucket, object) {
+		return
+	}
+	if !checkUnlocked(w, r, s, bucket, object, objectPath) {
+		return
+	}
+
+	cannedACL := r.Header.Get("x-amz-acl")
+	if cannedACL != "" && !acl.Valid(cannedACL) {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid canned ACL '%s'", cannedACL))
+		return
+	}
+
+	// like S3 the copy keeps the source's tags unless told to replace them
+	tags := source.Tags
+	switch r.Header.Get("x-amz-tagging-directive") {
+	case "", "COPY":
+	case "REPLACE":
+		if tags, err = parseTagging(r.Header.Get("x-amz-tagging")); err != nil {
+			s3err.Write(w, r, s3err.InvalidTag, fmt.Sprintf("Invalid tagging: %v", err))
+			return
+		}
+	default:
+		s3err.Write(w, r, s3err.InvalidArgument, "x-amz-tagging-directive must be COPY or REPLACE")
+		return
+	}
+
+	// the lock of the source is not copied, the destination's rules apply
+	lockMode, retainUntil, legalHold, ok := resolveLock(w, r, s, bucket, object)
+	if !ok {
+		return
+	}
+
+	sourceCustomerKey, ok := customerKeyFor(w, r, &source, sse.CopySourceHeaderPrefix)
+	if !ok {
+		return
+	}
+
+	customerKey, ok := parseCustomerKey(w, r, sse.CustomerHeaderPrefix)
+	if !ok {
+		return
+	}
+	encryption, err := sse.Resolve(bucket, r.Header.Get("x-amz-server-side-encryption"), customerKey)
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid server-side encryption: %v", err))
+		return
+	}
+	fingerprint, err := fingerprintOf(customerKey)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error fingerprinting customer-provided key")
+		return
+	}
+	compression, err := compress.Resolve(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading compression configuration")
+		return
+	}
+
+	if !checkObjectSize(w, r, int64(source.Size)) {
+		return
+	}
+	if !checkQuota(w, r, s, bucket, objectPath, int64(source.Size)) {
+		return
+	}
+
+	body, _, err := blob.Open(&source, sourceCustomerKey)
+	if err != nil {
+		log.Printf("Error opening object '%s': %v", source.ObjectKey, err)
+		s3err.Write(w, r, s3err.InternalError, "Error reading source object data")
+		return
+	}
+	defer body.Close()
+
+	newObject := &models.Object{
+		ObjectKey:              objectPath,
+		ContentType:            source.ContentType,
+		ACL:                    cannedACL,
+		Encryption:             encryption,
+		CustomerKeyFingerprint: fingerprint,
+		Compression:            compression,
+		Tags:                   tags,
+		LockMode:               lockMode,
+		RetainUntil:            retainUntil,
+		LegalHold:              legalHold,
+	}
+	// copying large objects takes a while, other requests go on meanwhile
+	storagelock.Unlocked(r, func() {
+		err = blob.Write(newObject, body, customerKey)
+	})
+	if err != nil {
+		log.Printf("Error copying '%s' to '%s': %v", source.ObjectKey, objectPath, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving file data")
+		return
+	}
+	newObject.LastModified = time.Now()
+
+	replaceObject(s, newObject, bucket)
+
+	for i, b := range s.Buckets {
+		if b.Name == bucket {
+			s.Buckets[i].LastModified = time.Now()
+			s.Buckets[i].Status = "Active"
+			csv.CSVUpdateBucketMetaData(&s.Buckets[i])
+			break
+		}
+	}
+
+	xmlData, err := xml.MarshalIndent(models.CopyObjectResult{LastModified: newObject.LastModified}, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	emit(r, s, "s3:ObjectCreated:Copy", bucket, object, int64(newObject.Size))
+
+	setEncryptionHeaders(w, encryption, customerKey)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
@@ -0,0 +1,65 @@
+package objectHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"A3S/internal/sse"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// parseCustomerKey parses the SSE-C headers starting with prefix, writing the
+// error response on failure. Like S3, keys are only taken over TLS.
+func parseCustomerKey(w http.ResponseWriter, r *http.Request, prefix string) (*sse.CustomerKey, bool) {
+	customerKey, err := sse.ParseCustomerKey(r.Header, prefix)
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid customer-provided key: %v", err))
+		return nil, false
+	}
+	if customerKey != nil && r.TLS == nil {
+		s3err.Write(w, r, s3err.InvalidRequest, "Requests specifying Server Side Encryption with Customer provided keys must be made over a secure connection.")
+		return nil, false
+	}
+	return customerKey, true
+}
+
+// customerKeyFor parses the SSE-C headers starting with prefix and checks them
+// against the key object was stored with, writing the error response on failure
+func customerKeyFor(w http.ResponseWriter, r *http.Request, object *models.Object, prefix string) (*sse.CustomerKey, bool) {
+	customerKey, ok := parseCustomerKey(w, r, prefix)
+	if !ok {
+		return nil, false
+	}
+
+	switch err := sse.CheckCustomerKey(customerKey, object.CustomerKeyFingerprint); err {
+	case nil:
+		return customerKey, true
+	case sse.ErrCustomerKeyMismatch:
+		log.Printf("Wrong customer-provided key for object '%s'", object.ObjectKey)
+		s3err.Write(w, r, s3err.AccessDenied, "")
+	default:
+		s3err.Write(w, r, s3err.InvalidArgument, err.Error())
+	}
+	return nil, false
+}
+
+func fingerprintOf(customerKey *sse.CustomerKey) (string, error) {
+	if customerKey == nil {
+		return "", nil
+	}
+	return customerKey.Fingerprint()
+}
+
+// setEncryptionHeaders tells the client how an object is stored; SSE-C objects
+// echo the key's MD5 instead of x-amz-server-side-encryption, like S3
+func setEncryptionHeaders(w http.ResponseWriter, encryption string, customerKey *sse.CustomerKey) {
+	if customerKey != nil {
+		w.Header().Set(sse.CustomerHeaderPrefix+"algorithm", sse.AES256)
+		w.Header().Set(sse.CustomerHeaderPrefix+"key-MD5", customerKey.KeyMD5)
+		return
+	}
+	if encryption != "" {
+		w.Header().Set("x-amz-server-side-encryption", encryption)
+	}
+}
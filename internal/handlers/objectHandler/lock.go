@@ -0,0 +1,230 @@
+package objectHandl
+
+import (
+	"A3S/internal/csv"
+	"A3S/internal/models"
+	"A3S/internal/objectlock"
+	"A3S/internal/policy"
+	"A3S/internal/s3err"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxLockDocumentSize bounds the Retention and LegalHold request bodies
+const maxLockDocumentSize = 4 * 1024
+
+func ObjectRetentionHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutObjectRetention(w, r, s)
+	case http.MethodGet:
+		GetObjectRetention(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+func ObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutObjectLegalHold(w, r, s)
+	case http.MethodGet:
+		GetObjectLegalHold(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+// findLockableObject looks up an object of a bucket with object lock enabled,
+// answering the request itself when there is none
+func findLockableObject(w http.ResponseWriter, r *http.Request, s *models.Storage, bucketName, objectKey string) *models.Object {
+	bucket, object := findObject(s, bucketName, objectKey)
+	if bucket == nil || object == nil {
+		s3err.Write(w, r, s3err.NoSuchKey, "")
+		return nil
+	}
+	config, err := objectlock.Load(bucketName)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading object lock configuration")
+		return nil
+	}
+	if config == nil {
+		s3err.Write(w, r, s3err.InvalidRequest, fmt.Sprintf("Invalid request: %v", objectlock.ErrNotEnabled))
+		return nil
+	}
+	return object
+}
+
+func PutObjectRetention(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	objectKey := r.PathValue("object")
+
+	object := findLockableObject(w, r, s, bucketName, objectKey)
+	if object == nil {
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutObjectRetention", bucketName, objectKey) {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxLockDocumentSize+1))
+	if err != nil || len(data) > maxLockDocumentSize {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading retention")
+		return
+	}
+	retention, err := objectlock.ParseRetention(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed retention: %v", err))
+		return
+	}
+
+	var until time.Time
+	if retention.RetainUntilDate != nil {
+		until = *retention.RetainUntilDate
+	}
+	bypass := objectlock.Bypass(r, bucketName, objectKey)
+	if err := objectlock.CheckRetentionChange(object, retention.Mode, until, bypass); err != nil {
+		s3err.Write(w, r, s3err.AccessDenied, fmt.Sprintf("Access Denied: %v", err))
+		return
+	}
+
+	object.LockMode = retention.Mode
+	object.RetainUntil = until
+	csv.CSVUpdateObjectMetaData(object, bucketName)
+	log.Printf("Retention of object '%s' set to '%s' until %s", objectKey, retention.Mode, until.Format(time.RFC3339))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetObjectRetention(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	objectKey := r.PathValue("object")
+
+	object := findLockableObject(w, r, s, bucketName, objectKey)
+	if object == nil {
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetObjectRetention", bucketName, objectKey) {
+		return
+	}
+	if object.LockMode == "" {
+		s3err.Write(w, r, s3err.NoSuchObjectLockConfiguration, "")
+		return
+	}
+
+	until := object.RetainUntil
+	xmlData, err := xml.MarshalIndent(models.ObjectRetention{Mode: object.LockMode, RetainUntilDate: &until}, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+func PutObjectLegalHold(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	objectKey := r.PathValue("object")
+
+	object := findLockableObject(w, r, s, bucketName, objectKey)
+	if object == nil {
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:PutObjectLegalHold", bucketName, objectKey) {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxLockDocumentSize+1))
+	if err != nil || len(data) > maxLockDocumentSize {
+		s3err.Write(w, r, s3err.InvalidRequest, "Error reading legal hold")
+		return
+	}
+	hold, err := objectlock.ParseLegalHold(data)
+	if err != nil {
+		s3err.Write(w, r, s3err.MalformedXML, fmt.Sprintf("Malformed legal hold: %v", err))
+		return
+	}
+
+	object.LegalHold = hold.Status == "ON"
+	csv.CSVUpdateObjectMetaData(object, bucketName)
+	log.Printf("Legal hold of object '%s' set to %s", objectKey, hold.Status)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func GetObjectLegalHold(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	bucketName := r.PathValue("bucket")
+	objectKey := r.PathValue("object")
+
+	object := findLockableObject(w, r, s, bucketName, objectKey)
+	if object == nil {
+		return
+	}
+	if !policy.Authorize(w, r, s, "s3:GetObjectLegalHold", bucketName, objectKey) {
+		return
+	}
+
+	hold := models.ObjectLegalHold{Status: "OFF"}
+	if object.LegalHold {
+		hold.Status = "ON"
+	}
+	xmlData, err := xml.MarshalIndent(hold, "", "  ")
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
+
+// checkUnlocked answers 403 when objectPath exists and is locked against
+// deletion and overwrites
+func checkUnlocked(w http.ResponseWriter, r *http.Request, s *models.Storage, bucket, key, objectPath string) bool {
+	for i := range s.Object {
+		if s.Object[i].ObjectKey != objectPath {
+			continue
+		}
+		if err := objectlock.CheckRemoval(&s.Object[i], objectlock.Bypass(r, bucket, key)); err != nil {
+			s3err.Write(w, r, s3err.AccessDenied, fmt.Sprintf("Access Denied: %v", err))
+			return false
+		}
+		break
+	}
+	return true
+}
+
+// resolveLock returns the lock a new object gets, checking the caller may set
+// it explicitly
+func resolveLock(w http.ResponseWriter, r *http.Request, s *models.Storage, bucket, key string) (string, time.Time, bool, bool) {
+	mode, until, hold, err := objectlock.Resolve(bucket, r.Header)
+	if err != nil {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid object lock: %v", err))
+		return "", time.Time{}, false, false
+	}
+	if r.Header.Get("x-amz-object-lock-mode") != "" && !policy.Authorize(w, r, s, "s3:PutObjectRetention", bucket, key) {
+		return "", time.Time{}, false, false
+	}
+	if r.Header.Get("x-amz-object-lock-legal-hold") != "" && !policy.Authorize(w, r, s, "s3:PutObjectLegalHold", bucket, key) {
+		return "", time.Time{}, false, false
+	}
+	return mode, until, hold, true
+}
+
+// setLockHeaders reports the lock of an object on GET and HEAD
+func setLockHeaders(w http.ResponseWriter, object *models.Object) {
+	if object.LockMode != "" {
+		w.Header().Set("x-amz-object-lock-mode", object.LockMode)
+		w.Header().Set("x-amz-object-lock-retain-until-date", object.RetainUntil.Format(time.RFC3339))
+	}
+	if object.LegalHold {
+		w.Header().Set("x-amz-object-lock-legal-hold", "ON")
+	}
+}
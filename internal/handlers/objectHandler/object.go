@@ -1,42 +1,96 @@
 package objectHandl
 
 import (
+	"A3S/internal/acl"
+	"A3S/internal/blob"
+	"A3S/internal/compress"
+	"A3S/internal/cors"
 	"A3S/internal/csv"
+	"A3S/internal/events"
 	bucketHandl "A3S/internal/handlers/bucketHandler"
 	"A3S/internal/models"
+	"A3S/internal/objectkey"
+	"A3S/internal/objectlock"
+	"A3S/internal/policy"
+	"A3S/internal/quota"
+	"A3S/internal/replication"
+	"A3S/internal/s3err"
+	"A3S/internal/sse"
+	"A3S/internal/storagelock"
 	"A3S/internal/utils"
-	"encoding/xml"
+	"bufio"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
 func ObjectHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	// "/{bucket}/" addresses the bucket itself
+	if r.PathValue("object") == "" {
+		bucketHandl.BucketHandler(w, r, s)
+		return
+	}
+
+	if r.Method == http.MethodOptions {
+		cors.Preflight(w, r)
+		return
+	}
+	cors.ApplyHeaders(w, r)
+
+	query := r.URL.Query()
+	switch {
+	case query.Has("acl"):
+		ObjectACLHandler(w, r, s)
+		return
+	case query.Has("retention"):
+		ObjectRetentionHandler(w, r, s)
+		return
+	case query.Has("legal-hold"):
+		ObjectLegalHoldHandler(w, r, s)
+		return
+	}
+
 	switch r.Method {
-	case http.MethodGet:
+	case http.MethodGet, http.MethodHead:
 		GetObject(w, r, s)
 	case http.MethodPut:
+		if r.Header.Get("x-amz-copy-source") != "" {
+			CopyObject(w, r, s)
+			return
+		}
 		PutObject(w, r, s)
 	case http.MethodDelete:
 		DeleteObject(w, r, s)
 	default:
-		utils.WriteXMLError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
 	}
 }
 
+func ObjectACLHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	switch r.Method {
+	case http.MethodPut:
+		PutObjectACL(w, r, s)
+	case http.MethodGet:
+		GetObjectACL(w, r, s)
+	default:
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
+	}
+}
+
+// GetObject also answers HEAD, which gets the same headers without a body
 func GetObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
-	if r.Method != http.MethodGet {
-		utils.WriteXMLError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
 		return
 	}
 
 	bucketName := r.PathValue("bucket")
 	objectKey := r.PathValue("object")
-	objectPath := filepath.Join("data", bucketName, objectKey)
+	objectPath := utils.DataPath(bucketName, objectKey)
 
 	// searching bucket
 	var bucket *models.Bucket
@@ -47,7 +101,11 @@ func GetObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 		}
 	}
 	if bucket == nil {
-		utils.WriteXMLError(w, "Bucket not found", http.StatusNotFound)
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
+		return
+	}
+
+	if !policy.Authorize(w, r, s, "s3:GetObject", bucketName, objectKey) {
 		return
 	}
 
@@ -60,93 +118,154 @@ func GetObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 		}
 	}
 	if object == nil {
-		utils.WriteXMLError(w, "Object not found", http.StatusNotFound)
+		s3err.Write(w, r, s3err.NoSuchKey, "")
 		return
 	}
 
-	// adding header Content-Type for XML-response
-	w.Header().Set("Content-Type", "application/xml")
-	xmlData, err := xml.MarshalIndent(object, "", "  ")
+	customerKey, ok := customerKeyFor(w, r, object, sse.CustomerHeaderPrefix)
+	if !ok {
+		return
+	}
+
+	body, _, err := blob.Open(object, customerKey)
 	if err != nil {
-		utils.WriteXMLError(w, "Failed to generate XML", http.StatusInternalServerError)
+		log.Printf("Error opening object '%s': %v", objectPath, err)
+		s3err.Write(w, r, s3err.InternalError, "Error reading object data")
 		return
 	}
+	defer body.Close()
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(xmlData)
+	if object.ContentType != "" {
+		w.Header().Set("Content-Type", object.ContentType)
+	}
+	setEncryptionHeaders(w, object.Encryption, customerKey)
+	if object.Tags != "" {
+		w.Header().Set("x-amz-tagging-count", strconv.Itoa(tagCount(object.Tags)))
+	}
+	if object.ReplicationStatus != "" {
+		w.Header().Set("x-amz-replication-status", object.ReplicationStatus)
+	}
+	setLockHeaders(w, object)
+
+	// ServeContent answers Range and conditional requests
+	storagelock.Unlocked(r, func() {
+		http.ServeContent(w, r, objectKey, object.LastModified, body)
+	})
 }
 
 func PutObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	object := r.PathValue("object")
 	bucket := r.PathValue("bucket")
 
-	bucketDir := filepath.Join("data", bucket)
+	if e, err := objectkey.Validate(object); err != nil {
+		s3err.Write(w, r, e, fmt.Sprintf("Invalid object key: %v", err))
+		return
+	}
+
+	bucketDir := utils.DataPath(bucket)
 	objectPath := filepath.Join(bucketDir, object)
 
 	if _, err := os.Stat(bucketDir); os.IsNotExist(err) {
-		utils.WriteXMLError(w, "Bucket directory not found", http.StatusConflict)
+		s3err.Write(w, r, s3err.NoSuchBucket, "")
 		return
 	}
 
-	// checking existing object
-	objectIndex := -1
-	for i, o := range s.Object {
-		if o.ObjectKey == objectPath {
-			objectIndex = i
-			break
-		}
+	if !policy.Authorize(w, r, s, "s3:PutObject", bucket, object) {
+		return
+	}
+	// without versioning an overwrite would destroy the locked data
+	if !checkUnlocked(w, r, s, bucket, object, objectPath) {
+		return
 	}
 
-	// deleteing object form list CSV and storage
-	if objectIndex != -1 {
-		csv.CSVDeleteObject(&s.Object[objectIndex], bucket)
-		s.Object = append(s.Object[:objectIndex], s.Object[objectIndex+1:]...)
+	// without x-amz-acl the object follows its bucket's ACL
+	cannedACL := r.Header.Get("x-amz-acl")
+	if cannedACL != "" && !acl.Valid(cannedACL) {
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid canned ACL '%s'", cannedACL))
+		return
 	}
 
-	// Creating new file object
-	file, err := os.Create(objectPath)
+	tags, err := parseTagging(r.Header.Get("x-amz-tagging"))
 	if err != nil {
-		utils.WriteXMLError(w, "Error creating or overwriting file", http.StatusInternalServerError)
+		s3err.Write(w, r, s3err.InvalidTag, fmt.Sprintf("Invalid tagging: %v", err))
 		return
 	}
-	defer file.Close()
 
-	// writing data from request
-	bytesWritten, err := io.Copy(file, r.Body)
-	if err != nil {
-		utils.WriteXMLError(w, "Error saving file data", http.StatusInternalServerError)
+	lockMode, retainUntil, legalHold, ok := resolveLock(w, r, s, bucket, object)
+	if !ok {
 		return
 	}
 
-	// getting file info
-	fileInfo, err := file.Stat()
+	// copies made by another server's replication are marked so that they
+	// are not replicated onwards; anyone else could use the mark to keep
+	// their writes from being replicated
+	replicationStatus := ""
+	if r.Header.Get("x-amz-replication-status") == replication.StatusReplica && replication.Replicator(r) {
+		replicationStatus = replication.StatusReplica
+	}
+
+	customerKey, ok := parseCustomerKey(w, r, sse.CustomerHeaderPrefix)
+	if !ok {
+		return
+	}
+	encryption, err := sse.Resolve(bucket, r.Header.Get("x-amz-server-side-encryption"), customerKey)
 	if err != nil {
-		utils.WriteXMLError(w, "Error obtaining file information", http.StatusInternalServerError)
+		s3err.Write(w, r, s3err.InvalidArgument, fmt.Sprintf("Invalid server-side encryption: %v", err))
+		return
+	}
+	fingerprint, err := fingerprintOf(customerKey)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error fingerprinting customer-provided key")
+		return
+	}
+	compression, err := compress.Resolve(bucket)
+	if err != nil {
+		s3err.Write(w, r, s3err.InternalError, "Error reading compression configuration")
 		return
 	}
 
-	var objectContentType string
-	if bytesWritten > 0 {
-		// Reading 512 bytes
-		file.Seek(0, io.SeekStart)
-		buffer := make([]byte, 512)
-		if _, err := file.Read(buffer); err != nil {
-			utils.WriteXMLError(w, "Error reading file for content type detection", http.StatusInternalServerError)
-			return
-		}
-		objectContentType = http.DetectContentType(buffer)
+	// uploads with a known length are turned away before anything is stored
+	if !checkObjectSize(w, r, r.ContentLength) {
+		return
+	}
+	if r.ContentLength >= 0 && !checkQuota(w, r, s, bucket, objectPath, r.ContentLength) {
+		return
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxObjectSize())
 
-	// creating new object and saving it in storage
 	newObject := &models.Object{
-		ObjectKey:    objectPath,
-		Size:         int(fileInfo.Size()),
-		ContentType:  objectContentType,
-		LastModified: time.Now(),
+		ObjectKey:              objectPath,
+		ACL:                    cannedACL,
+		Encryption:             encryption,
+		CustomerKeyFingerprint: fingerprint,
+		Compression:            compression,
+		Tags:                   tags,
+		ReplicationStatus:      replicationStatus,
+		LockMode:               lockMode,
+		RetainUntil:            retainUntil,
+		LegalHold:              legalHold,
 	}
 
-	s.Object = append(s.Object, *newObject)
-	csv.CSVObjectWriter(newObject, bucket)
+	// writing data from request, other requests go on meanwhile
+	storagelock.Unlocked(r, func() {
+		// sniffing the content type from the first 512 bytes before they are stored
+		body := bufio.NewReaderSize(r.Body, 512)
+		if head, _ := body.Peek(512); len(head) > 0 {
+			newObject.ContentType = http.DetectContentType(head)
+		}
+		err = blob.Write(newObject, body, customerKey)
+	})
+	if err != nil {
+		writeBodyError(w, r, objectPath, err)
+		return
+	}
+	if !checkQuota(w, r, s, bucket, objectPath, int64(newObject.Size)) {
+		blob.Release(newObject)
+		return
+	}
+	newObject.LastModified = time.Now()
+
+	replaceObject(s, newObject, bucket)
 
 	// Refreshing bucket data
 	for i, b := range s.Buckets {
@@ -158,34 +277,26 @@ func PutObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 		}
 	}
 
-	utils.WriteXMLError(w, fmt.Sprintf("Object '%s' created or overwritten successfully!", object), http.StatusOK)
+	emit(r, s, "s3:ObjectCreated:Put", bucket, object, int64(newObject.Size))
+
+	setEncryptionHeaders(w, encryption, customerKey)
+	w.WriteHeader(http.StatusOK)
 }
 
 func DeleteObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	if r.Method != http.MethodDelete {
-		utils.WriteXMLError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
 		return
 	}
 
 	bucketName := r.PathValue("bucket")
 	objectKey := r.PathValue("object")
 
-	filePath := filepath.Join("data", bucketName, objectKey)
-	log.Printf("Attempting to delete file at path: %s", filePath)
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		log.Printf("File not found at path: %s", filePath)
-		utils.WriteXMLError(w, "Object file not found", http.StatusNotFound)
-		return
-	}
+	filePath := utils.DataPath(bucketName, objectKey)
 
-	err := os.Remove(filePath)
-	if err != nil {
-		log.Printf("Error while deleting file: %v", err)
-		utils.WriteXMLError(w, "Failed to delete object file", http.StatusInternalServerError)
+	if !policy.Authorize(w, r, s, "s3:DeleteObject", bucketName, objectKey) {
 		return
 	}
-	log.Printf("Object file '%s' deleted successfully", filePath)
 
 	// delete object from storage
 	objectIndex := -1
@@ -199,12 +310,26 @@ func DeleteObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	// 404 error
 	if objectIndex == -1 {
 		log.Printf("Object not found in storage: %s", objectKey)
-		utils.WriteXMLError(w, "Object not found in storage", http.StatusNotFound)
+		s3err.Write(w, r, s3err.NoSuchKey, "")
 		return
 	}
 
+	if err := objectlock.CheckRemoval(&s.Object[objectIndex], objectlock.Bypass(r, bucketName, objectKey)); err != nil {
+		s3err.Write(w, r, s3err.AccessDenied, fmt.Sprintf("Access Denied: %v", err))
+		return
+	}
+
+	// chunks shared with other objects stay until their last reference is gone
+	if err := blob.Release(&s.Object[objectIndex]); err != nil {
+		log.Printf("Error while releasing object data: %v", err)
+		s3err.Write(w, r, s3err.InternalError, "Failed to delete object data")
+		return
+	}
+	log.Printf("Object data of '%s' released", filePath)
+
 	// delete object from CSV
-	csv.CSVObjectWriter(&s.Object[objectIndex], bucketName)
+	csv.CSVDeleteObject(&s.Object[objectIndex], bucketName)
+	deletedSize := int64(s.Object[objectIndex].Size)
 	s.Object = append(s.Object[:objectIndex], s.Object[objectIndex+1:]...)
 	log.Printf("Object '%s' removed from memory storage", objectKey)
 
@@ -220,15 +345,52 @@ func DeleteObject(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	// if Bucket doesn't found refreshing status
 	if bucket != nil {
 		log.Printf("Updating status of bucket: %s", bucket.Name)
+		quota.Add(bucket, -1, -deletedSize)
 		bucketHandl.UpdateBucketStatus(bucket, s)
 		csv.CSVUpdateBucketMetaData(bucket)
 	} else {
 		log.Printf("Bucket '%s' not found for status update", bucketName)
 	}
 
+	emit(r, s, "s3:ObjectRemoved:Delete", bucketName, objectKey, 0)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// replaceObject records newObject, releasing the data of the object it
+// overwrites, and updates the bucket's usage
+func replaceObject(s *models.Storage, newObject *models.Object, bucket string) {
+	if b, objects, bytes := usageDelta(s, bucket, newObject.ObjectKey, int64(newObject.Size)); b != nil {
+		quota.Add(b, objects, bytes)
+	}
+
+	for i, o := range s.Object {
+		if o.ObjectKey == newObject.ObjectKey {
+			csv.CSVDeleteObject(&s.Object[i], bucket)
+			if err := blob.Release(&s.Object[i]); err != nil {
+				log.Printf("Error releasing data of overwritten object '%s': %v", o.ObjectKey, err)
+			}
+			s.Object = append(s.Object[:i], s.Object[i+1:]...)
+			break
+		}
+	}
+
+	s.Object = append(s.Object, *newObject)
+	csv.CSVObjectWriter(newObject, bucket)
+}
+
+// emit publishes an object event to webhooks and event streams
+func emit(r *http.Request, s *models.Storage, event, bucket, key string, size int64) {
+	owner := ""
+	for _, b := range s.Buckets {
+		if b.Name == bucket {
+			owner = b.Owner
+			break
+		}
+	}
+	events.Publish(events.New(r, event, bucket, owner, key, size))
+}
+
 func CreateObjectHandler(s *models.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ObjectHandler(w, r, s)
@@ -0,0 +1,45 @@
+package objectHandl
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/quota"
+	"A3S/internal/s3err"
+	"log"
+	"net/http"
+)
+
+// checkQuota rejects storing size bytes at objectPath when it would take the
+// bucket over a hard quota; overwriting an object only counts the difference
+func checkQuota(w http.ResponseWriter, r *http.Request, s *models.Storage, bucketName, objectPath string, size int64) bool {
+	bucket, objects, bytes := usageDelta(s, bucketName, objectPath, size)
+	if bucket == nil {
+		return true
+	}
+
+	if err := quota.Check(bucket, objects, bytes); err != nil {
+		log.Printf("Rejected write to '%s': %v", objectPath, err)
+		s3err.Write(w, r, s3err.QuotaExceeded, err.Error())
+		return false
+	}
+	return true
+}
+
+// usageDelta is how the usage of bucketName changes when size bytes are stored at objectPath
+func usageDelta(s *models.Storage, bucketName, objectPath string, size int64) (*models.Bucket, int64, int64) {
+	var bucket *models.Bucket
+	for i := range s.Buckets {
+		if s.Buckets[i].Name == bucketName {
+			bucket = &s.Buckets[i]
+			break
+		}
+	}
+
+	objects, bytes := int64(1), size
+	for _, o := range s.Object {
+		if o.ObjectKey == objectPath {
+			objects, bytes = 0, size-int64(o.Size)
+			break
+		}
+	}
+	return bucket, objects, bytes
+}
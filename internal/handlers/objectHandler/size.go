@@ -0,0 +1,43 @@
+package objectHandl
+
+import (
+	"A3S/internal/auth"
+	"A3S/internal/limits"
+	"A3S/internal/s3err"
+	"A3S/internal/utils"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// maxObjectSize is the largest object a PUT or copy may store
+func maxObjectSize() int64 {
+	return *utils.MaxObjectSize << 20
+}
+
+// checkObjectSize rejects objects over --max-object-size-mb; a negative size
+// is an upload of unknown length, which is cut off while it is read instead
+func checkObjectSize(w http.ResponseWriter, r *http.Request, size int64) bool {
+	if size > maxObjectSize() {
+		s3err.Write(w, r, s3err.EntityTooLarge, "")
+		return false
+	}
+	return true
+}
+
+// writeBodyError answers an upload to objectPath whose body could not be stored
+func writeBodyError(w http.ResponseWriter, r *http.Request, objectPath string, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		s3err.Write(w, r, s3err.EntityTooLarge, "")
+	case errors.Is(err, auth.ErrPayloadMismatch):
+		s3err.Write(w, r, s3err.XAmzContentSHA256Mismatch, "")
+	case limits.TimedOut(err):
+		log.Printf("Upload of '%s' timed out: %v", objectPath, err)
+		s3err.Write(w, r, s3err.RequestTimeout, "")
+	default:
+		log.Printf("Error writing object '%s': %v", objectPath, err)
+		s3err.Write(w, r, s3err.InternalError, "Error saving file data")
+	}
+}
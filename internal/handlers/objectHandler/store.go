@@ -0,0 +1,79 @@
+package objectHandl
+
+import (
+	"A3S/internal/blob"
+	"A3S/internal/compress"
+	"A3S/internal/csv"
+	"A3S/internal/models"
+	"A3S/internal/objectkey"
+	"A3S/internal/objectlock"
+	"A3S/internal/quota"
+	"A3S/internal/sse"
+	"A3S/internal/utils"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StoreObject writes an object on behalf of the server itself, such as a
+// delivered access log. It takes the same path as PutObject without the
+// request: the bucket's default encryption, compression and retention apply,
+// and quotas and object locks are respected. The caller holds the storage
+// lock.
+func StoreObject(s *models.Storage, bucket, key, contentType string, body io.Reader) error {
+	if _, err := objectkey.Validate(key); err != nil {
+		return err
+	}
+	b, existing := findObject(s, bucket, key)
+	if b == nil {
+		return fmt.Errorf("bucket '%s' not found", bucket)
+	}
+	objectPath := utils.DataPath(bucket, key)
+
+	if existing != nil {
+		if err := objectlock.CheckRemoval(existing, false); err != nil {
+			return err
+		}
+	}
+
+	encryption, err := sse.Resolve(bucket, "", nil)
+	if err != nil {
+		return err
+	}
+	compression, err := compress.Resolve(bucket)
+	if err != nil {
+		return err
+	}
+	lockMode, retainUntil, legalHold, err := objectlock.Resolve(bucket, nil)
+	if err != nil {
+		return err
+	}
+
+	newObject := &models.Object{
+		ObjectKey:   objectPath,
+		ContentType: contentType,
+		Encryption:  encryption,
+		Compression: compression,
+		LockMode:    lockMode,
+		RetainUntil: retainUntil,
+		LegalHold:   legalHold,
+	}
+	if err := blob.Write(newObject, body, nil); err != nil {
+		return err
+	}
+	_, objects, bytes := usageDelta(s, bucket, objectPath, int64(newObject.Size))
+	if err := quota.Check(b, objects, bytes); err != nil {
+		blob.Release(newObject)
+		return err
+	}
+	newObject.LastModified = time.Now()
+
+	replaceObject(s, newObject, bucket)
+
+	b.LastModified = time.Now()
+	b.Status = "Active"
+	csv.CSVUpdateBucketMetaData(b)
+
+	emit(nil, s, "s3:ObjectCreated:Put", bucket, key, int64(newObject.Size))
+	return nil
+}
@@ -0,0 +1,46 @@
+package objectHandl
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// limits S3 puts on object tags
+const (
+	maxTags        = 10
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+)
+
+// parseTagging validates x-amz-tagging, "key1=value1&key2=value2", and returns
+// it in a canonical form for the object metadata
+func parseTagging(header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+	tags, err := url.ParseQuery(header)
+	if err != nil {
+		return "", fmt.Errorf("tags must be URL query encoded: %v", err)
+	}
+	if len(tags) > maxTags {
+		return "", fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	for key, values := range tags {
+		if key == "" || len(key) > maxTagKeyLen {
+			return "", fmt.Errorf("tag keys must be 1 to %d characters", maxTagKeyLen)
+		}
+		if len(values) > 1 {
+			return "", fmt.Errorf("tag '%s' is given more than once", key)
+		}
+		if len(values[0]) > maxTagValueLen {
+			return "", fmt.Errorf("tag values must be at most %d characters", maxTagValueLen)
+		}
+	}
+	return tags.Encode(), nil
+}
+
+// tagCount is the value of x-amz-tagging-count
+func tagCount(tags string) int {
+	values, _ := url.ParseQuery(tags)
+	return len(values)
+}
@@ -1,8 +1,9 @@
 package rootHandl
 
 import (
+	"A3S/internal/auth"
 	"A3S/internal/models"
-	"A3S/internal/utils"
+	"A3S/internal/s3err"
 	"encoding/xml"
 	"net/http"
 )
@@ -12,27 +13,32 @@ func RootHandler(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	case http.MethodGet:
 		GetRoot(w, r, s)
 	default:
-		utils.WriteXMLError(w, "method not founded", http.StatusMethodNotAllowed)
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
 	}
 }
 
 func GetRoot(w http.ResponseWriter, r *http.Request, s *models.Storage) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		s3err.Write(w, r, s3err.MethodNotAllowed, "")
 		return
 	}
 
 	// response header XML
 	w.Header().Set("Content-Type", "application/xml")
 
-	if s.Buckets == nil {
-		utils.WriteXMLError(w, "data is empty , plese create bucket", http.StatusInternalServerError)
+	// only the caller's own buckets are listed, root sees everything
+	caller := auth.Caller(r)
+	var buckets []models.Bucket
+	for _, b := range s.Buckets {
+		if caller == auth.RootUser || b.Owner == caller {
+			buckets = append(buckets, b)
+		}
 	}
 
 	// buckets list to XML
-	xmlData, err := xml.MarshalIndent(s.Buckets, "", "  ")
+	xmlData, err := xml.MarshalIndent(buckets, "", "  ")
 	if err != nil {
-		http.Error(w, "Failed to generate XML", http.StatusInternalServerError)
+		s3err.Write(w, r, s3err.InternalError, "Failed to generate XML")
 		return
 	}
 
@@ -0,0 +1,104 @@
+package health
+
+import (
+	"A3S/internal/models"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+)
+
+// Version is the release of the server, set at build time with
+// -ldflags "-X A3S/internal/health.Version=v1.2.3"
+var Version = "dev"
+
+var ready atomic.Bool
+
+// SetReady marks whether the server should receive traffic: it is set once
+// the metadata is loaded and cleared again when the server shuts down
+func SetReady(value bool) {
+	ready.Store(value)
+}
+
+// Healthz answers 200 as long as the process serves requests
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// Readyz answers 200 when the server can take requests, and 503 listing
+// the failed checks otherwise
+func Readyz(dir string, minFreeBytes uint64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var failures []string
+		if !ready.Load() {
+			failures = append(failures, "metadata not loaded or shutting down")
+		}
+		if err := checkWritable(dir); err != nil {
+			failures = append(failures, fmt.Sprintf("storage root not writable: %v", err))
+		}
+		if minFreeBytes > 0 {
+			free, err := freeBytes(dir)
+			switch {
+			case err != nil:
+				failures = append(failures, fmt.Sprintf("free disk space unknown: %v", err))
+			case free < minFreeBytes:
+				failures = append(failures, fmt.Sprintf("free disk space %d bytes is below %d", free, minFreeBytes))
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(failures, "\n"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+func checkWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
+
+// VersionHandler answers the release and build information of the binary
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	info := models.BuildInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	xmlData, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to generate XML", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(xmlData)
+}
@@ -0,0 +1,222 @@
+package iam
+
+import (
+	"A3S/internal/csv"
+	"A3S/internal/models"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	StatusActive   = "Active"
+	StatusInactive = "Inactive"
+)
+
+var (
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserNotFound = errors.New("user not found")
+	ErrKeyNotFound  = errors.New("access key not found")
+	ErrInvalidName  = errors.New("invalid user name")
+	ErrNoActiveKey  = errors.New("user is inactive or has no active access key")
+
+	validUserName = regexp.MustCompile(`^[A-Za-z0-9+=,.@_-]{1,64}$`)
+)
+
+// Load fills the storage with users and access keys persisted on disk
+func Load(s *models.Storage) error {
+	users, err := csv.CSVLoadUsers()
+	if err != nil {
+		return fmt.Errorf("loading users: %w", err)
+	}
+	keys, err := csv.CSVLoadAccessKeys()
+	if err != nil {
+		return fmt.Errorf("loading access keys: %w", err)
+	}
+	s.Users = users
+	s.AccessKeys = keys
+	return nil
+}
+
+func FindUser(s *models.Storage, name string) *models.User {
+	for i := range s.Users {
+		if s.Users[i].Name == name {
+			return &s.Users[i]
+		}
+	}
+	return nil
+}
+
+func FindAccessKey(s *models.Storage, accessKeyID string) *models.AccessKey {
+	for i := range s.AccessKeys {
+		if s.AccessKeys[i].AccessKeyID == accessKeyID {
+			return &s.AccessKeys[i]
+		}
+	}
+	return nil
+}
+
+// Authenticate returns the secret and owner of an access key that is usable
+// right now, i.e. both the key and its user are active
+func Authenticate(s *models.Storage, accessKeyID string) (secret, user string, ok bool) {
+	key := FindAccessKey(s, accessKeyID)
+	if key == nil || key.Status != StatusActive {
+		return "", "", false
+	}
+	u := FindUser(s, key.UserName)
+	if u == nil || u.Status != StatusActive {
+		return "", "", false
+	}
+	return key.SecretAccessKey, u.Name, true
+}
+
+// SigningKey returns the first active access key of an active user, for
+// signing on the user's behalf
+func SigningKey(s *models.Storage, userName string) (*models.AccessKey, error) {
+	user := FindUser(s, userName)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Status != StatusActive {
+		return nil, ErrNoActiveKey
+	}
+	for i := range s.AccessKeys {
+		if s.AccessKeys[i].UserName == userName && s.AccessKeys[i].Status == StatusActive {
+			return &s.AccessKeys[i], nil
+		}
+	}
+	return nil, ErrNoActiveKey
+}
+
+func CreateUser(s *models.Storage, name string) (*models.User, error) {
+	if !validUserName.MatchString(name) || name == "root" {
+		return nil, fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	if FindUser(s, name) != nil {
+		return nil, ErrUserExists
+	}
+
+	s.Users = append(s.Users, models.User{
+		Name:         name,
+		Status:       StatusActive,
+		CreationTime: time.Now(),
+	})
+	if err := csv.CSVSaveUsers(s.Users); err != nil {
+		return nil, err
+	}
+	return &s.Users[len(s.Users)-1], nil
+}
+
+// DeleteUser removes the user together with all of their access keys
+func DeleteUser(s *models.Storage, name string) error {
+	index := -1
+	for i, u := range s.Users {
+		if u.Name == name {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return ErrUserNotFound
+	}
+	s.Users = append(s.Users[:index], s.Users[index+1:]...)
+
+	var keys []models.AccessKey
+	for _, k := range s.AccessKeys {
+		if k.UserName != name {
+			keys = append(keys, k)
+		}
+	}
+	s.AccessKeys = keys
+
+	if err := csv.CSVSaveUsers(s.Users); err != nil {
+		return err
+	}
+	return csv.CSVSaveAccessKeys(s.AccessKeys)
+}
+
+func SetUserStatus(s *models.Storage, name, status string) (*models.User, error) {
+	user := FindUser(s, name)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	user.Status = status
+	return user, csv.CSVSaveUsers(s.Users)
+}
+
+// CreateAccessKey issues a new key pair; the returned secret is only shown once
+func CreateAccessKey(s *models.Storage, userName string) (models.AccessKey, error) {
+	if FindUser(s, userName) == nil {
+		return models.AccessKey{}, ErrUserNotFound
+	}
+
+	key := models.AccessKey{
+		AccessKeyID:     "AKIA" + randomString(12, "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"),
+		SecretAccessKey: randomSecret(),
+		UserName:        userName,
+		Status:          StatusActive,
+		CreationTime:    time.Now(),
+	}
+	s.AccessKeys = append(s.AccessKeys, key)
+	return key, csv.CSVSaveAccessKeys(s.AccessKeys)
+}
+
+func SetAccessKeyStatus(s *models.Storage, accessKeyID, status string) (*models.AccessKey, error) {
+	key := FindAccessKey(s, accessKeyID)
+	if key == nil {
+		return nil, ErrKeyNotFound
+	}
+	key.Status = status
+	return key, csv.CSVSaveAccessKeys(s.AccessKeys)
+}
+
+// RotateAccessKey issues a replacement key for the same user and deactivates
+// the old one, so it can still be re-enabled if a client wasn't updated in time
+func RotateAccessKey(s *models.Storage, accessKeyID string) (models.AccessKey, error) {
+	old := FindAccessKey(s, accessKeyID)
+	if old == nil {
+		return models.AccessKey{}, ErrKeyNotFound
+	}
+	old.Status = StatusInactive
+	return CreateAccessKey(s, old.UserName)
+}
+
+func DeleteAccessKey(s *models.Storage, accessKeyID string) error {
+	for i, k := range s.AccessKeys {
+		if k.AccessKeyID == accessKeyID {
+			s.AccessKeys = append(s.AccessKeys[:i], s.AccessKeys[i+1:]...)
+			return csv.CSVSaveAccessKeys(s.AccessKeys)
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// UserAccessKeys lists a user's keys without their secrets
+func UserAccessKeys(s *models.Storage, userName string) []models.AccessKey {
+	var keys []models.AccessKey
+	for _, k := range s.AccessKeys {
+		if k.UserName == userName {
+			k.SecretAccessKey = ""
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func randomString(n int, alphabet string) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	for i := range buf {
+		buf[i] = alphabet[int(buf[i])%len(alphabet)]
+	}
+	return string(buf)
+}
+
+func randomSecret() string {
+	buf := make([]byte, 30)
+	rand.Read(buf)
+	return base64.StdEncoding.EncodeToString(buf)
+}
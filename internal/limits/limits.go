@@ -0,0 +1,116 @@
+package limits
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// grace is how long a request body may take before its average rate is
+// held against MinThroughput, so that slow starts are not punished
+const grace = 10 * time.Second
+
+// set from the flags before the servers start
+var (
+	// ReadTimeout is how long a request body may stall without a byte arriving
+	ReadTimeout = 30 * time.Second
+	// WriteTimeout is how long a single write of the response may block
+	WriteTimeout = 30 * time.Second
+	// MinThroughput is the lowest average rate of a request body in bytes
+	// per second, 0 turns the check off
+	MinThroughput int64 = 1024
+)
+
+// ErrTooSlow is returned by request bodies that arrive slower than MinThroughput
+var ErrTooSlow = errors.New("request body is arriving too slowly")
+
+// TimedOut reports whether err comes from a request body that stalled or
+// arrived too slowly
+func TimedOut(err error) bool {
+	return errors.Is(err, ErrTooSlow) || errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// Middleware puts deadlines on every read of the request body and every
+// write of the response instead of on the whole request, so uploads and
+// downloads of any size and long-lived event streams go through while a
+// client that stops sending or reading is cut off
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &body{ReadCloser: r.Body, rc: rc}
+		}
+		// nothing is written while the handler works out its answer
+		rc.SetWriteDeadline(time.Time{})
+
+		next.ServeHTTP(&writer{ResponseWriter: w, rc: rc}, r)
+
+		// the server drains the rest of the body and flushes what is left
+		// of the response once the handler returns
+		rc.SetReadDeadline(time.Now().Add(ReadTimeout))
+		rc.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	})
+}
+
+type body struct {
+	io.ReadCloser
+	rc    *http.ResponseController
+	start time.Time
+	n     int64
+	err   error
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	// the clocks start with the first read, not while the request waits
+	// for the storage lock or the handler checks it
+	if b.start.IsZero() {
+		b.start = time.Now()
+		b.rc.SetReadDeadline(b.start.Add(ReadTimeout))
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+
+	switch {
+	case err == io.EOF:
+		// the connection goes back to the server's own deadlines
+		b.rc.SetReadDeadline(time.Time{})
+	case err != nil:
+	case b.tooSlow():
+		// a past deadline makes the server drop the connection
+		b.rc.SetReadDeadline(time.Now())
+		b.err = ErrTooSlow
+		return n, b.err
+	default:
+		b.rc.SetReadDeadline(time.Now().Add(ReadTimeout))
+	}
+	return n, err
+}
+
+func (b *body) tooSlow() bool {
+	elapsed := time.Since(b.start)
+	if MinThroughput <= 0 || elapsed < grace {
+		return false
+	}
+	return float64(b.n) < float64(MinThroughput)*elapsed.Seconds()
+}
+
+type writer struct {
+	http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.rc.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach Flush for event streams
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
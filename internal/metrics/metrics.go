@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"io"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	latencyBuckets  = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	metadataBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+	requests = newCounterVec("a3s_http_requests_total",
+		"HTTP requests by handler, method and status code.", "handler", "method", "code")
+	requestDuration = newHistogramVec("a3s_http_request_duration_seconds",
+		"Time taken to answer HTTP requests.", latencyBuckets, "handler", "method")
+	bytesIn = newCounterVec("a3s_http_request_bytes_total",
+		"Bytes read from request bodies.", "handler")
+	bytesOut = newCounterVec("a3s_http_response_bytes_total",
+		"Bytes written in response bodies.", "handler")
+	metadataDuration = newHistogramVec("a3s_metadata_operation_duration_seconds",
+		"Time taken by reads and writes of the CSV metadata files.", metadataBuckets, "operation")
+)
+
+// subresources are the query parameters that select a handler, anything else
+// is left out of the handler label to bound its values
+var subresources = []string{
+	"acl", "policy", "cors", "website", "encryption", "compression", "notification",
+	"events", "replication", "object-lock", "retention", "legal-hold", "logging",
+}
+
+// methodName is the method label of r; methods outside the standard set are
+// counted as "other", as a client may send any token there
+func methodName(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return r.Method
+	}
+	return "other"
+}
+
+// handlerName names the handler r is routed to, such as "object" or "bucket_acl"
+func handlerName(r *http.Request) string {
+	path := strings.Trim(r.URL.Path, "/")
+	var name string
+	switch {
+	case path == "":
+		return "root"
+	case strings.HasPrefix(path, "_admin/"):
+		return "admin"
+	case path == "_healthz", path == "_readyz", path == "_version":
+		return strings.TrimPrefix(path, "_")
+	case strings.HasPrefix(path, "_"):
+		return "other"
+	case !strings.Contains(path, "/"):
+		name = "bucket"
+	default:
+		name = "object"
+	}
+
+	query := r.URL.Query()
+	for _, sub := range subresources {
+		if query.Has(sub) {
+			return name + "_" + strings.ReplaceAll(sub, "-", "_")
+		}
+	}
+	return name
+}
+
+// Middleware records the count, latency and traffic of every request
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		handler, method := handlerName(r), methodName(r)
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+
+		next.ServeHTTP(recorder, r)
+
+		requests.add(1, handler, method, strconv.Itoa(recorder.status))
+		requestDuration.observe(time.Since(start).Seconds(), handler, method)
+		bytesIn.add(float64(body.n), handler)
+		bytesOut.add(float64(recorder.n), handler)
+	})
+}
+
+// ObserveMetadata records the duration of a metadata operation started at start;
+// it is meant to be deferred
+func ObserveMetadata(operation string, start time.Time) {
+	metadataDuration.observe(time.Since(start).Seconds(), operation)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler(s *models.Storage) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		requests.write(w)
+		requestDuration.write(w)
+		bytesIn.write(w)
+		bytesOut.write(w)
+		metadataDuration.write(w)
+		for _, g := range storageGauges(s) {
+			g.write(w)
+		}
+	})
+}
+
+// storageGauges describes the buckets and objects as they are now
+func storageGauges(s *models.Storage) []*gauge {
+	buckets := &gauge{name: "a3s_buckets", help: "Number of buckets."}
+	objects := &gauge{name: "a3s_bucket_objects", help: "Number of objects in a bucket.", labels: []string{"bucket"}}
+	size := &gauge{name: "a3s_bucket_bytes", help: "Size of the objects in a bucket.", labels: []string{"bucket"}}
+	stored := &gauge{name: "a3s_bucket_stored_bytes",
+		help: "Disk space taken by the objects in a bucket after compression and encryption, before deduplication.", labels: []string{"bucket"}}
+	goroutines := &gauge{name: "a3s_goroutines", help: "Number of goroutines."}
+	heap := &gauge{name: "a3s_heap_alloc_bytes", help: "Bytes of allocated heap objects."}
+
+	s.Lock()
+	defer s.Unlock()
+
+	storedBytes := map[string]float64{}
+	root := utils.DataPath() + string(filepath.Separator)
+	for _, o := range s.Object {
+		bucket, _, _ := strings.Cut(strings.TrimPrefix(o.ObjectKey, root), string(filepath.Separator))
+		storedBytes[bucket] += float64(o.StoredSize)
+	}
+
+	names := make([]string, 0, len(s.Buckets))
+	byName := map[string]models.Bucket{}
+	for _, b := range s.Buckets {
+		names = append(names, b.Name)
+		byName[b.Name] = b
+	}
+	sort.Strings(names)
+
+	buckets.samples = []sample{{value: float64(len(names))}}
+	for _, name := range names {
+		b := byName[name]
+		objects.samples = append(objects.samples, sample{[]string{name}, float64(b.Objects)})
+		size.samples = append(size.samples, sample{[]string{name}, float64(b.Bytes)})
+		stored.samples = append(stored.samples, sample{[]string{name}, storedBytes[name]})
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	goroutines.samples = []sample{{value: float64(runtime.NumGoroutine())}}
+	heap.samples = []sample{{value: float64(mem.HeapAlloc)}}
+
+	return []*gauge{buckets, objects, size, stored, goroutines, heap}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	n           int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach Flush for event streams
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/xml"
+	"sync"
 	"time"
 )
 
@@ -11,6 +12,52 @@ type Bucket struct {
 	CreationTime time.Time `xml:"CreationTime"`
 	LastModified time.Time `xml:"LastModified"`
 	Status       string    `xml:"Status"`
+	Owner        string    `xml:"Owner"`
+	ACL          string    `xml:"ACL"`
+	// usage is kept up to date on every write and delete, quotas are set by admins
+	Objects int64       `xml:"-"`
+	Bytes   int64       `xml:"-"`
+	Quota   BucketQuota `xml:"-"`
+}
+
+// BucketQuota limits a bucket; zero means unlimited. Writes beyond a hard limit
+// are rejected, soft limits are only reported.
+type BucketQuota struct {
+	XMLName        xml.Name `xml:"BucketQuota"`
+	MaxObjects     int64    `xml:"MaxObjects"`
+	MaxBytes       int64    `xml:"MaxBytes"`
+	SoftMaxObjects int64    `xml:"SoftMaxObjects"`
+	SoftMaxBytes   int64    `xml:"SoftMaxBytes"`
+}
+
+type BucketQuotaStatus struct {
+	XMLName           xml.Name    `xml:"BucketQuotaStatus"`
+	Bucket            string      `xml:"Bucket"`
+	Objects           int64       `xml:"Objects"`
+	Bytes             int64       `xml:"Bytes"`
+	Quota             BucketQuota `xml:"BucketQuota"`
+	SoftLimitExceeded bool        `xml:"SoftLimitExceeded"`
+}
+
+type BucketQuotaStatusList struct {
+	XMLName xml.Name            `xml:"BucketQuotas"`
+	Buckets []BucketQuotaStatus `xml:"BucketQuotaStatus"`
+}
+
+// RateLimit caps the request rate and bandwidth of a client or a bucket;
+// zero means unlimited. Burst is how many requests may come at once, 0
+// allows one second's worth
+type RateLimit struct {
+	Bucket            string  `xml:"Bucket,omitempty"`
+	RequestsPerSecond float64 `xml:"RequestsPerSecond"`
+	Burst             int     `xml:"Burst"`
+	BytesPerSecond    int64   `xml:"BytesPerSecond"`
+}
+
+type RateLimitList struct {
+	XMLName xml.Name    `xml:"RateLimits"`
+	Client  RateLimit   `xml:"Client"`
+	Buckets []RateLimit `xml:"BucketRateLimit"`
 }
 
 type Object struct {
@@ -19,15 +66,300 @@ type Object struct {
 	Size         int       `xml:"Size"`
 	ContentType  string    `xml:"ContentType"`
 	LastModified time.Time `xml:"LastModified"`
+	ACL          string    `xml:"ACL,omitempty"`
+	Encryption   string    `xml:"ServerSideEncryption,omitempty"`
+	// salted fingerprint of the customer-provided key of SSE-C objects
+	CustomerKeyFingerprint string `xml:"-"`
+	// compression algorithm and size on disk of compressed objects
+	Compression string `xml:"-"`
+	StoredSize  int    `xml:"-"`
+	// hashes of the content-addressed chunks holding the stored data, nil for
+	// objects kept as a plain file at ObjectKey
+	Chunks []string `xml:"-"`
+	// tags from x-amz-tagging, kept in their URL query form
+	Tags string `xml:"-"`
+	// PENDING, COMPLETED or FAILED on replicated objects, REPLICA on copies
+	// received from another server
+	ReplicationStatus string `xml:"-"`
+	// object lock: retention mode and date, and whether a legal hold is set
+	LockMode    string    `xml:"-"`
+	RetainUntil time.Time `xml:"-"`
+	LegalHold   bool      `xml:"-"`
 }
 
+// Storage is guarded by its mutex: requests hold it through
+// storagelock.Middleware, background workers lock it themselves
 type Storage struct {
-	Buckets []Bucket
-	Object  []Object
+	sync.Mutex
+	Buckets    []Bucket
+	Object     []Object
+	Users      []User
+	AccessKeys []AccessKey
+}
+
+type User struct {
+	XMLName      xml.Name  `xml:"User"`
+	Name         string    `xml:"Name"`
+	Status       string    `xml:"Status"`
+	CreationTime time.Time `xml:"CreationTime"`
+}
+
+type AccessKey struct {
+	XMLName         xml.Name  `xml:"AccessKey"`
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey,omitempty"`
+	UserName        string    `xml:"UserName"`
+	Status          string    `xml:"Status"`
+	CreationTime    time.Time `xml:"CreationTime"`
+}
+
+type UserList struct {
+	XMLName xml.Name `xml:"Users"`
+	Users   []User   `xml:"User"`
+}
+
+type AccessKeyList struct {
+	XMLName    xml.Name    `xml:"AccessKeys"`
+	AccessKeys []AccessKey `xml:"AccessKey"`
+}
+
+// ErrorResponse is the S3 error document
+type ErrorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId,omitempty"`
+	HostID    string   `xml:"HostId,omitempty"`
+}
+
+type BuildInfo struct {
+	XMLName   xml.Name `xml:"BuildInfo"`
+	Version   string   `xml:"Version"`
+	Revision  string   `xml:"Revision,omitempty"`
+	BuildTime string   `xml:"BuildTime,omitempty"`
+	Modified  bool     `xml:"Modified"`
+	GoVersion string   `xml:"GoVersion"`
+	Platform  string   `xml:"Platform"`
+}
+
+type CopyObjectResult struct {
+	XMLName      xml.Name  `xml:"CopyObjectResult"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type PresignedURL struct {
+	XMLName xml.Name  `xml:"PresignedURL"`
+	Method  string    `xml:"Method"`
+	URL     string    `xml:"URL"`
+	Expires time.Time `xml:"Expires"`
+}
+
+type AccessControlPolicy struct {
+	XMLName           xml.Name `xml:"AccessControlPolicy"`
+	Owner             Owner    `xml:"Owner"`
+	AccessControlList []Grant  `xml:"AccessControlList>Grant"`
+}
+
+type Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type Grant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
+}
+
+type Grantee struct {
+	XMLNS       string `xml:"xmlns:xsi,attr"`
+	Type        string `xml:"xsi:type,attr"`
+	ID          string `xml:"ID,omitempty"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+	URI         string `xml:"URI,omitempty"`
+}
+
+type CORSConfiguration struct {
+	XMLName   xml.Name   `xml:"CORSConfiguration"`
+	CORSRules []CORSRule `xml:"CORSRule"`
+}
+
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// NotificationConfiguration follows the S3 document, with webhooks as the only
+// kind of destination
+type NotificationConfiguration struct {
+	XMLName  xml.Name               `xml:"NotificationConfiguration"`
+	Webhooks []WebhookConfiguration `xml:"WebhookConfiguration"`
+}
+
+type WebhookConfiguration struct {
+	ID       string              `xml:"Id,omitempty"`
+	Endpoint string              `xml:"Endpoint"`
+	Events   []string            `xml:"Event"`
+	Filter   *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+type NotificationFilter struct {
+	Rules []FilterRule `xml:"S3Key>FilterRule"`
+}
+
+type FilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// ReplicationConfiguration lists where new objects of a bucket are copied;
+// the first enabled rule whose filter matches an object decides its destination
+type ReplicationConfiguration struct {
+	XMLName xml.Name          `xml:"ReplicationConfiguration"`
+	Rules   []ReplicationRule `xml:"Rule"`
+}
+
+type ReplicationRule struct {
+	ID          string                 `xml:"ID,omitempty"`
+	Status      string                 `xml:"Status"`
+	Filter      *ReplicationFilter     `xml:"Filter,omitempty"`
+	Destination ReplicationDestination `xml:"Destination"`
+}
+
+// ReplicationFilter matches objects whose key starts with Prefix and which
+// carry all of Tags
+type ReplicationFilter struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// ReplicationDestination is a bucket on another server, reached through
+// Endpoint with the given credentials, or a directory the objects are
+// written to as plain files under Directory/Bucket/key. Endpoint and
+// Directory must be among those the operator allows.
+type ReplicationDestination struct {
+	Bucket    string `xml:"Bucket"`
+	Endpoint  string `xml:"Endpoint,omitempty"`
+	AccessKey string `xml:"AccessKey,omitempty"`
+	SecretKey string `xml:"SecretKey,omitempty"`
+	Directory string `xml:"Directory,omitempty"`
+}
+
+// ObjectLockConfiguration turns on object lock for a bucket, optionally with
+// a retention applied to every new object; once enabled it stays enabled
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled"`
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+type ObjectLockRule struct {
+	DefaultRetention DefaultRetention `xml:"DefaultRetention"`
+}
+
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"`
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+type ObjectRetention struct {
+	XMLName         xml.Name   `xml:"Retention"`
+	Mode            string     `xml:"Mode,omitempty"`
+	RetainUntilDate *time.Time `xml:"RetainUntilDate,omitempty"`
+}
+
+type ObjectLegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+// BucketLoggingStatus sends the access log of a bucket into TargetBucket;
+// without LoggingEnabled logging is off
+type BucketLoggingStatus struct {
+	XMLName        xml.Name        `xml:"BucketLoggingStatus"`
+	LoggingEnabled *LoggingEnabled `xml:"LoggingEnabled,omitempty"`
+}
+
+type LoggingEnabled struct {
+	TargetBucket string `xml:"TargetBucket"`
+	TargetPrefix string `xml:"TargetPrefix"`
+}
+
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	RoutingRules          []RoutingRule          `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type RoutingRule struct {
+	Condition *RoutingCondition `xml:"Condition,omitempty"`
+	Redirect  Redirect          `xml:"Redirect"`
+}
+
+type RoutingCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+type Redirect struct {
+	Protocol             string `xml:"Protocol,omitempty"`
+	HostName             string `xml:"HostName,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode,omitempty"`
+}
+
+type ServerSideEncryptionConfiguration struct {
+	XMLName xml.Name                   `xml:"ServerSideEncryptionConfiguration"`
+	Rules   []ServerSideEncryptionRule `xml:"Rule"`
+}
+
+type CompressionConfiguration struct {
+	XMLName   xml.Name `xml:"CompressionConfiguration"`
+	Algorithm string   `xml:"Algorithm"`
+}
+
+type CompressionStats struct {
+	XMLName xml.Name                 `xml:"CompressionStats"`
+	Buckets []BucketCompressionStats `xml:"Bucket"`
+}
+
+// BucketCompressionStats covers the compressed objects of one bucket
+type BucketCompressionStats struct {
+	Name         string `xml:"Name"`
+	Objects      int    `xml:"Objects"`
+	LogicalBytes int64  `xml:"LogicalBytes"`
+	StoredBytes  int64  `xml:"StoredBytes"`
+	Ratio        string `xml:"Ratio"`
 }
 
-type XMLErrorResponse struct {
-	XmlName xml.Name `xml:"Error"`
-	Message string   `xml:"Message"`
-	Code    int      `xml:"Code"`
+type ServerSideEncryptionRule struct {
+	ApplyServerSideEncryptionByDefault struct {
+		SSEAlgorithm string `xml:"SSEAlgorithm"`
+	} `xml:"ApplyServerSideEncryptionByDefault"`
 }
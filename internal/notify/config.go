@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+const MaxConfigSize = 64 * 1024
+
+// event names that can be subscribed to; "*" covers all events of a kind
+var supportedEvents = map[string]bool{
+	"s3:ObjectCreated:*":      true,
+	"s3:ObjectCreated:Put":    true,
+	"s3:ObjectCreated:Copy":   true,
+	"s3:ObjectRemoved:*":      true,
+	"s3:ObjectRemoved:Delete": true,
+}
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "NotificationConfiguration.xml")
+}
+
+// Load returns the bucket's notification configuration, or nil if there is none
+func Load(bucket string) (*models.NotificationConfiguration, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Save stores config; like S3, an empty configuration turns notifications off
+func Save(bucket string, config *models.NotificationConfiguration) error {
+	if len(config.Webhooks) == 0 {
+		err := os.Remove(configPath(bucket))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(bucket), data, 0o644)
+}
+
+func Parse(data []byte) (*models.NotificationConfiguration, error) {
+	var config models.NotificationConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+
+	ids := map[string]bool{}
+	for i, webhook := range config.Webhooks {
+		if webhook.ID != "" {
+			if ids[webhook.ID] {
+				return nil, fmt.Errorf("webhook %d: duplicate Id %q", i, webhook.ID)
+			}
+			ids[webhook.ID] = true
+		}
+
+		if err := checkEndpoint(webhook.Endpoint); err != nil {
+			return nil, fmt.Errorf("webhook %d: %v", i, err)
+		}
+
+		if len(webhook.Events) == 0 {
+			return nil, fmt.Errorf("webhook %d: at least one Event is required", i)
+		}
+		for _, event := range webhook.Events {
+			if !supportedEvents[event] {
+				return nil, fmt.Errorf("webhook %d: unsupported Event %q", i, event)
+			}
+		}
+
+		if webhook.Filter != nil {
+			seen := map[string]bool{}
+			for _, rule := range webhook.Filter.Rules {
+				name := strings.ToLower(rule.Name)
+				if name != "prefix" && name != "suffix" {
+					return nil, fmt.Errorf("webhook %d: filter rule Name must be prefix or suffix", i)
+				}
+				if seen[name] {
+					return nil, fmt.Errorf("webhook %d: filter rule %s given twice", i, name)
+				}
+				seen[name] = true
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// checkEndpoint only lets a webhook name a URL the operator allowed with
+// --notification-endpoints, as anyone who may configure notifications would
+// otherwise make the server send requests to any address it can reach
+func checkEndpoint(endpoint string) error {
+	if !slices.Contains(utils.SplitList(*utils.NotificationEndpoints), endpoint) {
+		return fmt.Errorf("Endpoint %q is not one of --notification-endpoints", endpoint)
+	}
+	return nil
+}
+
+// matches reports whether webhook subscribes to event on key
+func matches(webhook models.WebhookConfiguration, event, key string) bool {
+	subscribed := false
+	for _, e := range webhook.Events {
+		if e == event || (strings.HasSuffix(e, ":*") && strings.HasPrefix(event, strings.TrimSuffix(e, "*"))) {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return false
+	}
+
+	if webhook.Filter != nil {
+		for _, rule := range webhook.Filter.Rules {
+			switch strings.ToLower(rule.Name) {
+			case "prefix":
+				if !strings.HasPrefix(key, rule.Value) {
+					return false
+				}
+			case "suffix":
+				if !strings.HasSuffix(key, rule.Value) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
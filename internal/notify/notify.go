@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"A3S/internal/events"
+	"encoding/json"
+	"log"
+)
+
+// Emit queues e for every webhook of the bucket subscribed to it; it is
+// registered as a listener on the event bus
+func Emit(e events.Event) {
+	config, err := Load(e.Bucket)
+	if err != nil {
+		log.Printf("Error reading notification configuration of bucket '%s': %v", e.Bucket, err)
+		return
+	}
+	if config == nil {
+		return
+	}
+
+	for _, webhook := range config.Webhooks {
+		if !matches(webhook, e.Name, e.Key) {
+			continue
+		}
+		payload, err := json.Marshal(e.Message(webhook.ID))
+		if err != nil {
+			log.Printf("Error encoding event for bucket '%s': %v", e.Bucket, err)
+			continue
+		}
+		if err := enqueue(webhook.Endpoint, payload); err != nil {
+			log.Printf("Error queueing event for %s: %v", webhook.Endpoint, err)
+		}
+	}
+}
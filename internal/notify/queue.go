@@ -0,0 +1,204 @@
+package notify
+
+import (
+	"A3S/internal/utils"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	pollInterval = time.Second
+	maxBackoff   = time.Hour
+)
+
+// MaxAttempts is how often a delivery is tried before it is parked in the
+// failed directory, set from --delivery-attempts
+var MaxAttempts = 10
+
+// queued events survive restarts: each pending delivery is a file in queueDir
+func queueDir() string {
+	return utils.DataPath(".notifications")
+}
+
+func failedDir() string {
+	return filepath.Join(queueDir(), "failed")
+}
+
+var (
+	client = &http.Client{Timeout: 10 * time.Second}
+
+	stopCh = make(chan struct{})
+	doneCh = make(chan struct{})
+)
+
+type delivery struct {
+	Endpoint    string          `json:"endpoint"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastError   string          `json:"lastError,omitempty"`
+}
+
+func enqueue(endpoint string, payload []byte) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	// names sort in the order events happened
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), hex.EncodeToString(suffix))
+	return saveDelivery(filepath.Join(queueDir(), name), &delivery{
+		Endpoint:    endpoint,
+		Payload:     payload,
+		NextAttempt: time.Now(),
+	})
+}
+
+func saveDelivery(path string, d *delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Start delivers queued events in the background until Stop is called
+func Start() {
+	go func() {
+		defer close(doneCh)
+		for {
+			deliverDue()
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+	}()
+}
+
+// Stop waits for the delivery in progress to finish; events not yet
+// delivered stay queued for the next start
+func Stop(ctx context.Context) error {
+	close(stopCh)
+	select {
+	case <-doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func stopping() bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func deliverDue() {
+	// ReadDir sorts by name, so the oldest events go first
+	entries, err := os.ReadDir(queueDir())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error reading notification queue: %v", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		if stopping() {
+			return
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(queueDir(), entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var d delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			log.Printf("Dropping unreadable notification %s: %v", entry.Name(), err)
+			os.Remove(path)
+			continue
+		}
+		if time.Now().Before(d.NextAttempt) {
+			continue
+		}
+
+		// the allowed endpoints may have changed since the event was queued
+		if err := checkEndpoint(d.Endpoint); err != nil {
+			log.Printf("Dropping notification %s: %v", entry.Name(), err)
+			d.LastError = err.Error()
+			if err := saveDelivery(filepath.Join(failedDir(), entry.Name()), &d); err == nil {
+				os.Remove(path)
+			}
+			continue
+		}
+
+		err = post(d.Endpoint, d.Payload)
+		if err == nil {
+			os.Remove(path)
+			continue
+		}
+
+		d.Attempts++
+		d.LastError = err.Error()
+		if d.Attempts >= MaxAttempts {
+			log.Printf("Giving up on notification to %s after %d attempts: %v", d.Endpoint, d.Attempts, err)
+			if err := saveDelivery(filepath.Join(failedDir(), entry.Name()), &d); err == nil {
+				os.Remove(path)
+			}
+			continue
+		}
+
+		d.NextAttempt = time.Now().Add(backoff(d.Attempts))
+		log.Printf("Notification to %s failed (attempt %d), retrying at %s: %v", d.Endpoint, d.Attempts, d.NextAttempt.Format(time.RFC3339), err)
+		if err := saveDelivery(path, &d); err != nil {
+			log.Printf("Error updating notification %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+// backoff doubles the wait after every failed attempt, starting at one second
+func backoff(attempts int) time.Duration {
+	wait := time.Second << (attempts - 1)
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+func post(endpoint string, payload []byte) error {
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
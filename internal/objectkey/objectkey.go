@@ -0,0 +1,46 @@
+package objectkey
+
+import (
+	"A3S/internal/s3err"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxLength is the longest key S3 accepts, in bytes
+const MaxLength = 1024
+
+// reserved are bookkeeping files kept next to the objects of a bucket
+var reserved = map[string]bool{
+	"ObjectMetaData.csv":            true,
+	"BucketPolicy.json":             true,
+	"CORSConfiguration.xml":         true,
+	"WebsiteConfiguration.xml":      true,
+	"EncryptionConfiguration.xml":   true,
+	"CompressionConfiguration.xml":  true,
+	"NotificationConfiguration.xml": true,
+	"ReplicationConfiguration.xml":  true,
+	"ObjectLockConfiguration.xml":   true,
+	"LoggingConfiguration.xml":      true,
+	"RateLimitConfiguration.xml":    true,
+}
+
+// Validate rejects keys that would not map onto a file inside their bucket
+// directory, along with the names of the bucket's bookkeeping files
+func Validate(key string) (s3err.Error, error) {
+	if len(key) > MaxLength {
+		return s3err.KeyTooLong, fmt.Errorf("object key must be at most %d bytes", MaxLength)
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return s3err.InvalidArgument, errors.New("object key must not contain empty, '.' or '..' path segments")
+		}
+	}
+
+	if reserved[key] {
+		return s3err.InvalidArgument, fmt.Errorf("object key '%s' is reserved", key)
+	}
+
+	return s3err.Error{}, nil
+}
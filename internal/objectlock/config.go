@@ -0,0 +1,105 @@
+package objectlock
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const MaxConfigSize = 64 * 1024
+
+// retention modes
+const (
+	Governance = "GOVERNANCE"
+	Compliance = "COMPLIANCE"
+)
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "ObjectLockConfiguration.xml")
+}
+
+// Load returns the bucket's object lock configuration, or nil if object lock
+// was never enabled
+func Load(bucket string) (*models.ObjectLockConfiguration, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Save(bucket string, config *models.ObjectLockConfiguration) error {
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(bucket), data, 0o644)
+}
+
+// Enable turns on object lock for a new bucket, without a default retention
+func Enable(bucket string) error {
+	return Save(bucket, &models.ObjectLockConfiguration{ObjectLockEnabled: "Enabled"})
+}
+
+func Parse(data []byte) (*models.ObjectLockConfiguration, error) {
+	var config models.ObjectLockConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	// object lock cannot be turned off again
+	if config.ObjectLockEnabled != "Enabled" {
+		return nil, errors.New("ObjectLockEnabled must be Enabled")
+	}
+
+	if config.Rule != nil {
+		retention := config.Rule.DefaultRetention
+		if !validMode(retention.Mode) {
+			return nil, fmt.Errorf("DefaultRetention Mode must be %s or %s", Governance, Compliance)
+		}
+		if (retention.Days > 0) == (retention.Years > 0) || retention.Days < 0 || retention.Years < 0 {
+			return nil, errors.New("DefaultRetention needs either a positive Days or a positive Years")
+		}
+	}
+
+	return &config, nil
+}
+
+func ParseRetention(data []byte) (*models.ObjectRetention, error) {
+	var retention models.ObjectRetention
+	if err := xml.Unmarshal(data, &retention); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	// an empty Retention removes the retention
+	if retention.Mode == "" && retention.RetainUntilDate == nil {
+		return &retention, nil
+	}
+	if !validMode(retention.Mode) {
+		return nil, fmt.Errorf("Mode must be %s or %s", Governance, Compliance)
+	}
+	if retention.RetainUntilDate == nil || !retention.RetainUntilDate.After(time.Now()) {
+		return nil, errors.New("RetainUntilDate must be in the future")
+	}
+	return &retention, nil
+}
+
+func ParseLegalHold(data []byte) (*models.ObjectLegalHold, error) {
+	var hold models.ObjectLegalHold
+	if err := xml.Unmarshal(data, &hold); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	if hold.Status != "ON" && hold.Status != "OFF" {
+		return nil, errors.New("Status must be ON or OFF")
+	}
+	return &hold, nil
+}
+
+func validMode(mode string) bool {
+	return mode == Governance || mode == Compliance
+}
@@ -0,0 +1,99 @@
+package objectlock
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		err  string // "" when the configuration is valid
+	}{
+		{name: "enabled", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`},
+		{name: "default days", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>
+			<Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>30</Days></DefaultRetention></Rule></ObjectLockConfiguration>`},
+		{name: "default years", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>
+			<Rule><DefaultRetention><Mode>COMPLIANCE</Mode><Years>7</Years></DefaultRetention></Rule></ObjectLockConfiguration>`},
+		{name: "malformed", xml: `<ObjectLockConfiguration>`, err: "malformed XML"},
+		{name: "turned off", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Disabled</ObjectLockEnabled></ObjectLockConfiguration>`,
+			err: "must be Enabled"},
+		{name: "unknown mode", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>
+			<Rule><DefaultRetention><Mode>FOREVER</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>`, err: "Mode must be"},
+		{name: "days and years", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>
+			<Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>1</Days><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>`,
+			err: "either a positive Days or a positive Years"},
+		{name: "no period", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>
+			<Rule><DefaultRetention><Mode>GOVERNANCE</Mode></DefaultRetention></Rule></ObjectLockConfiguration>`,
+			err: "either a positive Days or a positive Years"},
+		{name: "negative days", xml: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>
+			<Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>-1</Days><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>`,
+			err: "either a positive Days or a positive Years"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.xml))
+			checkError(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		xml  string
+		err  string
+	}{
+		{name: "governance", xml: `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>` + future + `</RetainUntilDate></Retention>`},
+		{name: "compliance", xml: `<Retention><Mode>COMPLIANCE</Mode><RetainUntilDate>` + future + `</RetainUntilDate></Retention>`},
+		{name: "empty removes", xml: `<Retention></Retention>`},
+		{name: "past date", xml: `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>` + past + `</RetainUntilDate></Retention>`,
+			err: "must be in the future"},
+		{name: "mode without date", xml: `<Retention><Mode>GOVERNANCE</Mode></Retention>`, err: "must be in the future"},
+		{name: "date without mode", xml: `<Retention><RetainUntilDate>` + future + `</RetainUntilDate></Retention>`, err: "Mode must be"},
+		{name: "malformed", xml: `<Retention><Mode>`, err: "malformed XML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRetention([]byte(tt.xml))
+			checkError(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseLegalHold(t *testing.T) {
+	tests := []struct {
+		xml string
+		err string
+	}{
+		{`<LegalHold><Status>ON</Status></LegalHold>`, ""},
+		{`<LegalHold><Status>OFF</Status></LegalHold>`, ""},
+		{`<LegalHold><Status>on</Status></LegalHold>`, "Status must be ON or OFF"},
+		{`<LegalHold></LegalHold>`, "Status must be ON or OFF"},
+		{`<LegalHold>`, "malformed XML"},
+	}
+	for _, tt := range tests {
+		_, err := ParseLegalHold([]byte(tt.xml))
+		checkError(t, err, tt.err)
+	}
+}
+
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("got %v, want an error about %q", err, want)
+	}
+}
@@ -0,0 +1,105 @@
+package objectlock
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/policy"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrNotEnabled = errors.New("object lock is not enabled for this bucket")
+
+// Resolve returns the lock a new object gets: the x-amz-object-lock-* headers
+// of the request or else the bucket's default retention
+func Resolve(bucket string, header http.Header) (string, time.Time, bool, error) {
+	config, err := Load(bucket)
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+
+	mode := header.Get("x-amz-object-lock-mode")
+	until := header.Get("x-amz-object-lock-retain-until-date")
+	hold := header.Get("x-amz-object-lock-legal-hold")
+
+	if config == nil {
+		if mode != "" || until != "" || hold != "" {
+			return "", time.Time{}, false, ErrNotEnabled
+		}
+		return "", time.Time{}, false, nil
+	}
+
+	if hold != "" && hold != "ON" && hold != "OFF" {
+		return "", time.Time{}, false, errors.New("x-amz-object-lock-legal-hold must be ON or OFF")
+	}
+	legalHold := hold == "ON"
+
+	if mode == "" && until == "" {
+		if config.Rule == nil {
+			return "", time.Time{}, legalHold, nil
+		}
+		retention := config.Rule.DefaultRetention
+		return retention.Mode, time.Now().AddDate(retention.Years, 0, retention.Days), legalHold, nil
+	}
+
+	if !validMode(mode) || until == "" {
+		return "", time.Time{}, false, fmt.Errorf("x-amz-object-lock-mode must be %s or %s and come with x-amz-object-lock-retain-until-date", Governance, Compliance)
+	}
+	retainUntil, err := time.Parse(time.RFC3339, until)
+	if err != nil || !retainUntil.After(time.Now()) {
+		return "", time.Time{}, false, errors.New("x-amz-object-lock-retain-until-date must be a future RFC 3339 date")
+	}
+	return mode, retainUntil, legalHold, nil
+}
+
+// Retained reports whether object is under an unexpired retention
+func Retained(object *models.Object) bool {
+	return object.LockMode != "" && object.RetainUntil.After(time.Now())
+}
+
+// Bypass reports whether the request may override GOVERNANCE retention: it
+// must ask for it and be granted s3:BypassGovernanceRetention explicitly, as
+// the bucket owner is not by default
+func Bypass(r *http.Request, bucket, key string) bool {
+	if !strings.EqualFold(r.Header.Get("x-amz-bypass-governance-retention"), "true") {
+		return false
+	}
+	return policy.CheckExplicit(r, "s3:BypassGovernanceRetention", bucket, key)
+}
+
+// CheckRemoval returns why object may not be deleted or overwritten, or nil
+func CheckRemoval(object *models.Object, bypass bool) error {
+	if object.LegalHold {
+		return errors.New("object is under legal hold")
+	}
+	if !Retained(object) {
+		return nil
+	}
+	if object.LockMode == Compliance || !bypass {
+		return fmt.Errorf("object is under %s retention until %s", object.LockMode, object.RetainUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// CheckRetentionChange returns why the retention of object may not be set to
+// mode and until, or nil; retention can always be made stricter, but only
+// GOVERNANCE retention can be relaxed, and only with bypass
+func CheckRetentionChange(object *models.Object, mode string, until time.Time, bypass bool) error {
+	if !Retained(object) {
+		return nil
+	}
+	stricter := mode != "" && !until.Before(object.RetainUntil) &&
+		(mode == Compliance || object.LockMode == Governance)
+	if stricter {
+		return nil
+	}
+	if object.LockMode == Compliance {
+		return errors.New("COMPLIANCE retention cannot be shortened or removed")
+	}
+	if !bypass {
+		return errors.New("GOVERNANCE retention can only be shortened or removed with x-amz-bypass-governance-retention")
+	}
+	return nil
+}
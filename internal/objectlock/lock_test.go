@@ -0,0 +1,147 @@
+package objectlock
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckRemoval(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		object models.Object
+		bypass bool
+		err    string
+	}{
+		{name: "unlocked", object: models.Object{}},
+		{name: "retention expired", object: models.Object{LockMode: Compliance, RetainUntil: earlier}},
+		{name: "governance", object: models.Object{LockMode: Governance, RetainUntil: later}, err: "under GOVERNANCE retention"},
+		{name: "governance bypassed", object: models.Object{LockMode: Governance, RetainUntil: later}, bypass: true},
+		{name: "compliance", object: models.Object{LockMode: Compliance, RetainUntil: later}, err: "under COMPLIANCE retention"},
+		{name: "compliance is not bypassed", object: models.Object{LockMode: Compliance, RetainUntil: later}, bypass: true,
+			err: "under COMPLIANCE retention"},
+		{name: "legal hold", object: models.Object{LegalHold: true}, err: "legal hold"},
+		{name: "legal hold is not bypassed", object: models.Object{LegalHold: true, LockMode: Governance, RetainUntil: later},
+			bypass: true, err: "legal hold"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, CheckRemoval(&tt.object, tt.bypass), tt.err)
+		})
+	}
+}
+
+func TestCheckRetentionChange(t *testing.T) {
+	until := time.Now().Add(24 * time.Hour)
+	governance := models.Object{LockMode: Governance, RetainUntil: until}
+	compliance := models.Object{LockMode: Compliance, RetainUntil: until}
+
+	tests := []struct {
+		name   string
+		object models.Object
+		mode   string
+		until  time.Time
+		bypass bool
+		err    string
+	}{
+		{name: "not retained", object: models.Object{}, mode: Governance, until: until},
+		{name: "expired", object: models.Object{LockMode: Compliance, RetainUntil: time.Now().Add(-time.Hour)}},
+		{name: "governance extended", object: governance, mode: Governance, until: until.Add(time.Hour)},
+		{name: "governance kept", object: governance, mode: Governance, until: until},
+		{name: "governance to compliance", object: governance, mode: Compliance, until: until},
+		{name: "governance shortened", object: governance, mode: Governance, until: until.Add(-time.Hour), err: "x-amz-bypass-governance-retention"},
+		{name: "governance shortened with bypass", object: governance, mode: Governance, until: until.Add(-time.Hour), bypass: true},
+		{name: "governance removed", object: governance, err: "x-amz-bypass-governance-retention"},
+		{name: "governance removed with bypass", object: governance, bypass: true},
+		{name: "compliance extended", object: compliance, mode: Compliance, until: until.Add(time.Hour)},
+		{name: "compliance shortened", object: compliance, mode: Compliance, until: until.Add(-time.Hour), bypass: true,
+			err: "COMPLIANCE retention cannot"},
+		{name: "compliance to governance", object: compliance, mode: Governance, until: until.Add(time.Hour), bypass: true,
+			err: "COMPLIANCE retention cannot"},
+		{name: "compliance removed", object: compliance, bypass: true, err: "COMPLIANCE retention cannot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, CheckRetentionChange(&tt.object, tt.mode, tt.until, tt.bypass), tt.err)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := *utils.Dir
+	*utils.Dir = t.TempDir()
+	t.Cleanup(func() { *utils.Dir = dir })
+	for _, bucket := range []string{"plain", "locked", "defaulted"} {
+		if err := os.MkdirAll(utils.DataPath(bucket), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Enable("locked"); err != nil {
+		t.Fatal(err)
+	}
+	err := Save("defaulted", &models.ObjectLockConfiguration{ObjectLockEnabled: "Enabled",
+		Rule: &models.ObjectLockRule{DefaultRetention: models.DefaultRetention{Mode: Compliance, Days: 10}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	headers := func(mode, until, hold string) http.Header {
+		h := http.Header{}
+		for name, value := range map[string]string{"mode": mode, "retain-until-date": until, "legal-hold": hold} {
+			if value != "" {
+				h.Set("x-amz-object-lock-"+name, value)
+			}
+		}
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		bucket string
+		header http.Header
+		mode   string
+		until  time.Time
+		hold   bool
+		err    string
+	}{
+		{name: "no lock", bucket: "plain", header: headers("", "", "")},
+		{name: "lock headers without object lock", bucket: "plain", header: headers("", "", "ON"), err: "not enabled"},
+		{name: "no default", bucket: "locked", header: headers("", "", "")},
+		{name: "legal hold only", bucket: "locked", header: headers("", "", "ON"), hold: true},
+		{name: "explicit retention", bucket: "locked", header: headers(Governance, future.Format(time.RFC3339), "OFF"),
+			mode: Governance, until: future},
+		{name: "bucket default", bucket: "defaulted", header: headers("", "", ""),
+			mode: Compliance, until: time.Now().AddDate(0, 0, 10)},
+		{name: "headers override the default", bucket: "defaulted", header: headers(Governance, future.Format(time.RFC3339), ""),
+			mode: Governance, until: future},
+		{name: "mode without date", bucket: "locked", header: headers(Governance, "", ""), err: "must be GOVERNANCE or COMPLIANCE"},
+		{name: "unknown mode", bucket: "locked", header: headers("FOREVER", future.Format(time.RFC3339), ""), err: "must be GOVERNANCE or COMPLIANCE"},
+		{name: "past date", bucket: "locked", header: headers(Governance, "2001-01-01T00:00:00Z", ""), err: "future RFC 3339 date"},
+		{name: "bad legal hold", bucket: "locked", header: headers("", "", "yes"), err: "must be ON or OFF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, until, hold, err := Resolve(tt.bucket, tt.header)
+			checkError(t, err, tt.err)
+			if tt.err != "" {
+				return
+			}
+			if mode != tt.mode || hold != tt.hold {
+				t.Fatalf("got %q, hold %v; want %q, hold %v", mode, hold, tt.mode, tt.hold)
+			}
+			if until.Sub(tt.until).Abs() > time.Minute {
+				t.Fatalf("retain until %s, want %s", until, tt.until)
+			}
+		})
+	}
+}
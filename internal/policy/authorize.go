@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"A3S/internal/acl"
+	"A3S/internal/auth"
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"A3S/internal/utils"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+func policyPath(bucket string) string {
+	return utils.DataPath(bucket, "BucketPolicy.json")
+}
+
+// Load returns the raw policy attached to bucket, or nil if there is none
+func Load(bucket string) ([]byte, error) {
+	data, err := os.ReadFile(policyPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func Save(bucket string, data []byte) error {
+	return os.WriteFile(policyPath(bucket), data, 0o644)
+}
+
+func Delete(bucket string) error {
+	err := os.Remove(policyPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// NewRequest collects the caller, source address and transport of r
+func NewRequest(r *http.Request, action, bucket, key string) Request {
+	resource := bucket
+	if key != "" {
+		resource = bucket + "/" + key
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	req := Request{
+		Principal: auth.Caller(r),
+		Action:    action,
+		Resource:  resource,
+		SourceIP:  net.ParseIP(host),
+		Secure:    r.TLS != nil,
+	}
+	// s3:prefix is the prefix parameter of a listing, object keys are
+	// matched through the resource
+	if action == "s3:ListBucket" {
+		req.Prefix = r.URL.Query().Get("prefix")
+	}
+	return req
+}
+
+// Check decides whether the caller may perform action on bucket/key.
+// Root is always allowed, so that no bucket policy can lock the administrator
+// out. The bucket owner is allowed unless the bucket policy explicitly denies
+// them; everyone else needs an explicit Allow or a canned ACL grant. Buckets
+// created anonymously, on a server without credentials, have no owner and
+// stay open to everyone, as before policies existed.
+func Check(r *http.Request, s *models.Storage, action, bucket, key string) bool {
+	caller := auth.Caller(r)
+	if caller == auth.RootUser {
+		return true
+	}
+
+	decision, ok := evaluate(r, action, bucket, key)
+	if !ok {
+		return false
+	}
+	switch decision {
+	case Denied:
+		return false
+	case Allowed:
+		return true
+	}
+
+	for _, b := range s.Buckets {
+		if b.Name == bucket {
+			if b.Owner == "" || b.Owner == caller {
+				return true
+			}
+			return acl.Grants(effectiveACL(s, &b, key, action), caller, action)
+		}
+	}
+	// unknown buckets are left to the handler to report as missing
+	return true
+}
+
+// CheckExplicit is Check for actions S3 never grants implicitly, such as
+// s3:BypassGovernanceRetention: only root and an explicit Allow of the bucket
+// policy count, owning the bucket or an ACL grant does not
+func CheckExplicit(r *http.Request, action, bucket, key string) bool {
+	if auth.Caller(r) == auth.RootUser {
+		return true
+	}
+	decision, ok := evaluate(r, action, bucket, key)
+	return ok && decision == Allowed
+}
+
+// evaluate runs the bucket policy against the request; ok is false when the
+// policy could not be read
+func evaluate(r *http.Request, action, bucket, key string) (Decision, bool) {
+	data, err := Load(bucket)
+	if err != nil {
+		log.Printf("Error reading policy of bucket '%s': %v", bucket, err)
+		return NotMatched, false
+	}
+	if data == nil {
+		return NotMatched, true
+	}
+	p, err := Parse(data, bucket)
+	if err != nil {
+		log.Printf("Stored policy of bucket '%s' is invalid: %v", bucket, err)
+		return NotMatched, false
+	}
+	return p.Evaluate(NewRequest(r, action, bucket, key)), true
+}
+
+// effectiveACL picks the object's ACL for reads and the bucket's for writes;
+// objects stored without an explicit ACL follow their bucket
+func effectiveACL(s *models.Storage, bucket *models.Bucket, key, action string) string {
+	if key == "" || acl.IsWrite(action) {
+		return bucket.ACL
+	}
+	objectPath := utils.DataPath(bucket.Name, key)
+	for _, o := range s.Object {
+		if o.ObjectKey == objectPath && o.ACL != "" {
+			return o.ACL
+		}
+	}
+	return bucket.ACL
+}
+
+// Authorize runs Check and writes an AccessDenied response when it fails
+func Authorize(w http.ResponseWriter, r *http.Request, s *models.Storage, action, bucket, key string) bool {
+	if Check(r, s, action, bucket, key) {
+		return true
+	}
+	log.Printf("Access denied: %s by '%s' on %s/%s", action, auth.Caller(r), bucket, key)
+	s3err.Write(w, r, s3err.AccessDenied, "")
+	return false
+}
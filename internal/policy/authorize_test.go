@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"A3S/internal/acl"
+	"A3S/internal/auth"
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// authorizeFixture is a storage with the users alice, who owns the buckets,
+// and bob, and root signing as AKROOT
+func authorizeFixture(t *testing.T) *models.Storage {
+	t.Helper()
+	dir, accessKey, secretKey := *utils.Dir, *utils.AccessKey, *utils.SecretKey
+	*utils.Dir, *utils.AccessKey, *utils.SecretKey = t.TempDir(), "AKROOT", "root-secret"
+	t.Cleanup(func() { *utils.Dir, *utils.AccessKey, *utils.SecretKey = dir, accessKey, secretKey })
+
+	s := &models.Storage{
+		Buckets: []models.Bucket{
+			{Name: "private", Owner: "alice", ACL: acl.Private},
+			{Name: "public", Owner: "alice", ACL: acl.PublicRead},
+			{Name: "guarded", Owner: "alice", ACL: acl.PublicRead},
+			{Name: "broken", Owner: "alice", ACL: acl.PublicRead},
+			{Name: "ownerless"},
+		},
+		Users: []models.User{{Name: "alice", Status: "Active"}, {Name: "bob", Status: "Active"}},
+		AccessKeys: []models.AccessKey{
+			{AccessKeyID: "AKALICE", SecretAccessKey: "alice-secret", UserName: "alice", Status: "Active"},
+			{AccessKeyID: "AKBOB", SecretAccessKey: "bob-secret", UserName: "bob", Status: "Active"},
+		},
+	}
+	for _, b := range s.Buckets {
+		if err := os.MkdirAll(utils.DataPath(b.Name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the owner is locked out of deletes, bob may write and nobody else
+	// may read
+	guarded := `{"Statement":[
+		{"Effect":"Deny","Principal":{"AWS":"alice"},"Action":"s3:DeleteObject","Resource":"arn:aws:s3:::guarded/*"},
+		{"Effect":"Allow","Principal":{"AWS":"bob"},"Action":"s3:PutObject","Resource":"arn:aws:s3:::guarded/*"},
+		{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::guarded/*",
+			"Condition":{"StringNotEquals":{"aws:username":["alice","bob"]}}}
+	]}`
+	if err := Save("guarded", []byte(guarded)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save("broken", []byte(`{"Statement":`)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// signedAs returns r as the handlers see it once auth.Middleware has
+// verified it; an empty accessKey leaves the request anonymous
+func signedAs(t *testing.T, s *models.Storage, r *http.Request, accessKey, secretKey string) *http.Request {
+	t.Helper()
+	if accessKey != "" {
+		auth.SignRequest(r, accessKey, secretKey, time.Now())
+	}
+	var seen *http.Request
+	auth.Middleware(s, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	if seen == nil {
+		t.Fatalf("auth.Middleware refused the request as %q", accessKey)
+	}
+	return seen
+}
+
+func TestCheck(t *testing.T) {
+	s := authorizeFixture(t)
+	secrets := map[string]string{"AKROOT": "root-secret", "AKALICE": "alice-secret", "AKBOB": "bob-secret"}
+
+	tests := []struct {
+		name   string
+		key    string // access key signing the request, "" for anonymous
+		action string
+		bucket string
+		object string
+		want   bool
+	}{
+		{"root reads anything", "AKROOT", "s3:GetObject", "private", "a", true},
+		{"root beats an explicit deny", "AKROOT", "s3:GetObject", "guarded", "a", true},
+		{"root ignores a broken policy", "AKROOT", "s3:GetObject", "broken", "a", true},
+		{"owner writes", "AKALICE", "s3:PutObject", "private", "a", true},
+		{"owner denied explicitly", "AKALICE", "s3:DeleteObject", "guarded", "a", false},
+		{"other user on private", "AKBOB", "s3:GetObject", "private", "a", false},
+		{"other user on public read", "AKBOB", "s3:GetObject", "public", "a", true},
+		{"other user writes public read", "AKBOB", "s3:PutObject", "public", "a", false},
+		{"policy allows other user", "AKBOB", "s3:PutObject", "guarded", "a", true},
+		{"anonymous on private", "", "s3:GetObject", "private", "a", false},
+		{"anonymous on public read", "", "s3:GetObject", "public", "a", true},
+		{"anonymous lists public read", "", "s3:ListBucket", "public", "", true},
+		{"policy deny beats public read", "", "s3:GetObject", "guarded", "a", false},
+		{"broken policy fails closed", "AKALICE", "s3:GetObject", "broken", "a", false},
+		{"ownerless bucket is open", "", "s3:PutObject", "ownerless", "a", true},
+		{"unknown bucket is left to the handler", "AKBOB", "s3:GetObject", "missing", "a", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://s3.local/"+tt.bucket+"/"+tt.object, nil)
+			r = signedAs(t, s, r, tt.key, secrets[tt.key])
+			if got := Check(r, s, tt.action, tt.bucket, tt.object); got != tt.want {
+				t.Fatalf("Check = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckExplicit(t *testing.T) {
+	s := authorizeFixture(t)
+	secrets := map[string]string{"AKROOT": "root-secret", "AKALICE": "alice-secret", "AKBOB": "bob-secret"}
+
+	tests := []struct {
+		name   string
+		key    string
+		action string
+		want   bool
+	}{
+		{"root", "AKROOT", "s3:BypassGovernanceRetention", true},
+		{"owning the bucket is not enough", "AKALICE", "s3:BypassGovernanceRetention", false},
+		{"explicit allow", "AKBOB", "s3:PutObject", true},
+		{"anonymous", "", "s3:BypassGovernanceRetention", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "http://s3.local/guarded/a", nil)
+			r = signedAs(t, s, r, tt.key, secrets[tt.key])
+			if got := CheckExplicit(r, tt.action, "guarded", "a"); got != tt.want {
+				t.Fatalf("CheckExplicit = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
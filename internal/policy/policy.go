@@ -0,0 +1,327 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	EffectAllow = "Allow"
+	EffectDeny  = "Deny"
+
+	// S3 rejects bucket policies larger than 20 KB
+	MaxPolicySize = 20 * 1024
+)
+
+type Decision int
+
+const (
+	NotMatched Decision = iota
+	Allowed
+	Denied
+)
+
+// StringList accepts both "value" and ["value", ...] in policy documents
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("expected a string or a list of strings")
+	}
+	*l = many
+	return nil
+}
+
+// Principal is either "*" or {"AWS": [...]}
+type Principal struct {
+	Any bool
+	AWS StringList
+}
+
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single != "*" {
+			return errors.New(`principal must be "*" or {"AWS": [...]}`)
+		}
+		p.Any = true
+		return nil
+	}
+	var object struct {
+		AWS StringList `json:"AWS"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	p.AWS = object.AWS
+	return nil
+}
+
+func (p Principal) MarshalJSON() ([]byte, error) {
+	if p.Any {
+		return json.Marshal("*")
+	}
+	return json.Marshal(map[string]StringList{"AWS": p.AWS})
+}
+
+type Statement struct {
+	Sid       string                           `json:"Sid,omitempty"`
+	Effect    string                           `json:"Effect"`
+	Principal Principal                        `json:"Principal"`
+	Action    StringList                       `json:"Action"`
+	Resource  StringList                       `json:"Resource"`
+	Condition map[string]map[string]StringList `json:"Condition,omitempty"`
+}
+
+type Policy struct {
+	Version   string      `json:"Version,omitempty"`
+	ID        string      `json:"Id,omitempty"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Request describes the access being checked against a policy
+type Request struct {
+	Principal string // user name, "" when anonymous
+	Action    string
+	Resource  string
+	SourceIP  net.IP
+	Secure    bool
+	Prefix    string // prefix parameter of a ListBucket request
+}
+
+// Parse decodes and validates a policy attached to bucket
+func Parse(data []byte, bucket string) (*Policy, error) {
+	if len(data) > MaxPolicySize {
+		return nil, fmt.Errorf("policy exceeds %d bytes", MaxPolicySize)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("policy is not valid JSON: %v", err)
+	}
+	if len(p.Statement) == 0 {
+		return nil, errors.New("policy has no statements")
+	}
+
+	for i, st := range p.Statement {
+		if st.Effect != EffectAllow && st.Effect != EffectDeny {
+			return nil, fmt.Errorf("statement %d: Effect must be Allow or Deny", i)
+		}
+		if !st.Principal.Any && len(st.Principal.AWS) == 0 {
+			return nil, fmt.Errorf("statement %d: missing Principal", i)
+		}
+		if len(st.Action) == 0 {
+			return nil, fmt.Errorf("statement %d: missing Action", i)
+		}
+		for _, action := range st.Action {
+			if action != "*" && !strings.HasPrefix(action, "s3:") {
+				return nil, fmt.Errorf("statement %d: unsupported action %q", i, action)
+			}
+		}
+		if len(st.Resource) == 0 {
+			return nil, fmt.Errorf("statement %d: missing Resource", i)
+		}
+		for _, resource := range st.Resource {
+			name := strings.TrimPrefix(resource, "arn:aws:s3:::")
+			if name == resource || (name != bucket && !strings.HasPrefix(name, bucket+"/")) {
+				return nil, fmt.Errorf("statement %d: resource %q must be within arn:aws:s3:::%s", i, resource, bucket)
+			}
+		}
+		for operator, conditions := range st.Condition {
+			if _, ok := conditionOperators[operator]; !ok {
+				return nil, fmt.Errorf("statement %d: unsupported condition operator %q", i, operator)
+			}
+			for key := range conditions {
+				if _, ok := conditionKeys[key]; !ok {
+					return nil, fmt.Errorf("statement %d: unsupported condition key %q", i, key)
+				}
+			}
+		}
+	}
+
+	return &p, nil
+}
+
+// Evaluate applies the usual IAM rule: an explicit Deny wins over any Allow
+func (p *Policy) Evaluate(req Request) Decision {
+	decision := NotMatched
+	for _, st := range p.Statement {
+		if !st.matches(req) {
+			continue
+		}
+		if st.Effect == EffectDeny {
+			return Denied
+		}
+		decision = Allowed
+	}
+	return decision
+}
+
+func (st Statement) matches(req Request) bool {
+	return st.matchesPrincipal(req.Principal) &&
+		matchAny(st.Action, req.Action) &&
+		matchAny(st.Resource, "arn:aws:s3:::"+req.Resource) &&
+		st.matchesConditions(req)
+}
+
+func (st Statement) matchesPrincipal(user string) bool {
+	if st.Principal.Any {
+		return true
+	}
+	for _, p := range st.Principal.AWS {
+		if p == "*" {
+			return true
+		}
+		// accept both plain user names and arn:aws:iam::<account>:user/<name>
+		if i := strings.LastIndex(p, ":user/"); i != -1 {
+			p = p[i+len(":user/"):]
+		}
+		if user != "" && p == user {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if wildcardMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch supports the policy wildcards '*' and '?', which may also span '/'
+func wildcardMatch(pattern, value string) bool {
+	if pattern == "*" {
+		return true
+	}
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || pattern[0] != value[0] {
+				return false
+			}
+		}
+		pattern, value = pattern[1:], value[1:]
+	}
+	return value == ""
+}
+
+var conditionKeys = map[string]struct{}{
+	"aws:SourceIp":        {},
+	"aws:SecureTransport": {},
+	"aws:username":        {},
+	"s3:prefix":           {},
+}
+
+type conditionFunc func(req Request, key string, values []string) bool
+
+var conditionOperators = map[string]conditionFunc{
+	"IpAddress":       ipAddress,
+	"NotIpAddress":    func(req Request, key string, values []string) bool { return !ipAddress(req, key, values) },
+	"StringEquals":    stringEquals,
+	"StringNotEquals": func(req Request, key string, values []string) bool { return !stringEquals(req, key, values) },
+	"StringLike":      stringLike,
+	"StringNotLike":   func(req Request, key string, values []string) bool { return !stringLike(req, key, values) },
+	"Bool":            boolEquals,
+}
+
+// matchesConditions requires every operator/key block to hold; values inside one key are ORed
+func (st Statement) matchesConditions(req Request) bool {
+	for operator, conditions := range st.Condition {
+		check := conditionOperators[operator]
+		for key, values := range conditions {
+			if !check(req, key, values) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (req Request) conditionValue(key string) string {
+	switch key {
+	case "aws:SourceIp":
+		return req.SourceIP.String()
+	case "aws:SecureTransport":
+		if req.Secure {
+			return "true"
+		}
+		return "false"
+	case "aws:username":
+		return req.Principal
+	case "s3:prefix":
+		return req.Prefix
+	}
+	return ""
+}
+
+func ipAddress(req Request, key string, values []string) bool {
+	if key != "aws:SourceIp" || req.SourceIP == nil {
+		return false
+	}
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil && ip.Equal(req.SourceIP) {
+				return true
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(v); err == nil && network.Contains(req.SourceIP) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringEquals(req Request, key string, values []string) bool {
+	actual := req.conditionValue(key)
+	for _, v := range values {
+		if v == actual {
+			return true
+		}
+	}
+	return false
+}
+
+func stringLike(req Request, key string, values []string) bool {
+	actual := req.conditionValue(key)
+	for _, v := range values {
+		if wildcardMatch(v, actual) {
+			return true
+		}
+	}
+	return false
+}
+
+func boolEquals(req Request, key string, values []string) bool {
+	actual := req.conditionValue(key)
+	for _, v := range values {
+		if strings.EqualFold(v, actual) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		err    string // "" when the policy is valid
+	}{
+		{name: "valid", policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/*"}]}`},
+		{name: "lists and arn principal", policy: `{"Statement":[{"Effect":"Deny","Principal":{"AWS":["arn:aws:iam::1:user/bob","alice"]},
+			"Action":["s3:PutObject","s3:DeleteObject"],"Resource":["arn:aws:s3:::photos","arn:aws:s3:::photos/*"]}]}`},
+		{name: "not JSON", policy: `{"Statement":`, err: "not valid JSON"},
+		{name: "no statements", policy: `{"Statement":[]}`, err: "no statements"},
+		{name: "bad effect", policy: `{"Statement":[{"Effect":"Maybe","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/*"}]}`,
+			err: "Effect must be Allow or Deny"},
+		{name: "no principal", policy: `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/*"}]}`,
+			err: "missing Principal"},
+		{name: "principal other than star", policy: `{"Statement":[{"Effect":"Allow","Principal":"alice","Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/*"}]}`,
+			err: "principal must be"},
+		{name: "other service", policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"iam:CreateUser","Resource":"arn:aws:s3:::photos/*"}]}`,
+			err: "unsupported action"},
+		{name: "other bucket", policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::photosx/*"}]}`,
+			err: "must be within arn:aws:s3:::photos"},
+		{name: "no arn", policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"photos/*"}]}`,
+			err: "must be within"},
+		{name: "unknown operator", policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/*",
+			"Condition":{"DateGreaterThan":{"aws:CurrentTime":"2020-01-01"}}}]}`, err: "unsupported condition operator"},
+		{name: "unknown condition key", policy: `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/*",
+			"Condition":{"StringEquals":{"aws:Referer":"x"}}}]}`, err: "unsupported condition key"},
+		{name: "too large", policy: `{"Statement":[]}` + strings.Repeat(" ", MaxPolicySize), err: "exceeds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.policy), "photos")
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got %v, want an error about %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	const doc = `{"Statement":[
+		{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/public/*"},
+		{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:user/alice"},"Action":"s3:*","Resource":"arn:aws:s3:::photos/*"},
+		{"Effect":"Deny","Principal":{"AWS":"*"},"Action":"s3:DeleteObject","Resource":"arn:aws:s3:::photos/keep/*"},
+		{"Effect":"Allow","Principal":{"AWS":"bob"},"Action":"s3:ListBucket","Resource":"arn:aws:s3:::photos",
+			"Condition":{"StringLike":{"s3:prefix":"bob/*"}}},
+		{"Effect":"Allow","Principal":{"AWS":"carol"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::photos/office/*",
+			"Condition":{"IpAddress":{"aws:SourceIp":["10.0.0.0/8","192.0.2.7"]},"Bool":{"aws:SecureTransport":"true"}}},
+		{"Effect":"Allow","Principal":{"AWS":"dave"},"Action":"s3:Get?bject","Resource":"arn:aws:s3:::photos/${none}"}
+	]}`
+	p, err := Parse([]byte(doc), "photos")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	office := net.ParseIP("10.1.2.3")
+	tests := []struct {
+		name string
+		req  Request
+		want Decision
+	}{
+		{"anonymous public read", Request{Action: "s3:GetObject", Resource: "photos/public/cat.jpg"}, Allowed},
+		{"anonymous private read", Request{Action: "s3:GetObject", Resource: "photos/private/cat.jpg"}, NotMatched},
+		{"anonymous public write", Request{Action: "s3:PutObject", Resource: "photos/public/cat.jpg"}, NotMatched},
+		{"wildcard spans slashes", Request{Principal: "alice", Action: "s3:PutObject", Resource: "photos/a/b/c.jpg"}, Allowed},
+		{"arn principal is the user name", Request{Principal: "alice", Action: "s3:GetObject", Resource: "photos/x"}, Allowed},
+		{"object pattern misses the bucket", Request{Principal: "alice", Action: "s3:ListBucket", Resource: "photos"}, NotMatched},
+		{"deny wins over allow", Request{Principal: "alice", Action: "s3:DeleteObject", Resource: "photos/keep/cat.jpg"}, Denied},
+		{"deny of anyone covers anonymous", Request{Action: "s3:DeleteObject", Resource: "photos/keep/cat.jpg"}, Denied},
+		{"other principal", Request{Principal: "mallory", Action: "s3:GetObject", Resource: "photos/x"}, NotMatched},
+		{"prefix condition holds", Request{Principal: "bob", Action: "s3:ListBucket", Resource: "photos", Prefix: "bob/2024/"}, Allowed},
+		{"prefix condition fails", Request{Principal: "bob", Action: "s3:ListBucket", Resource: "photos", Prefix: "alice/"}, NotMatched},
+		{"ip and tls hold", Request{Principal: "carol", Action: "s3:GetObject", Resource: "photos/office/a", SourceIP: office, Secure: true}, Allowed},
+		{"single ip holds", Request{Principal: "carol", Action: "s3:GetObject", Resource: "photos/office/a", SourceIP: net.ParseIP("192.0.2.7"), Secure: true}, Allowed},
+		{"plain http", Request{Principal: "carol", Action: "s3:GetObject", Resource: "photos/office/a", SourceIP: office}, NotMatched},
+		{"outside network", Request{Principal: "carol", Action: "s3:GetObject", Resource: "photos/office/a", SourceIP: net.ParseIP("192.0.2.8"), Secure: true}, NotMatched},
+		{"no source address", Request{Principal: "carol", Action: "s3:GetObject", Resource: "photos/office/a", Secure: true}, NotMatched},
+		{"question mark matches one character", Request{Principal: "dave", Action: "s3:GetObject", Resource: "photos/${none}"}, Allowed},
+		{"question mark needs a character", Request{Principal: "dave", Action: "s3:Getbject", Resource: "photos/${none}"}, NotMatched},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Evaluate(tt.req); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"*", "", true},
+		{"a*", "a", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"*.jpg", "dir/cat.jpg", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"abc", "abcd", false},
+		{"**", "anything", true},
+	}
+	for _, tt := range tests {
+		if got := wildcardMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("wildcardMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package quota
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// ErrExceeded is wrapped by the error Check returns for writes over a hard limit
+var ErrExceeded = errors.New("QuotaExceeded")
+
+// Validate rejects negative limits and soft limits above their hard limit
+func Validate(q models.BucketQuota) error {
+	if q.MaxObjects < 0 || q.MaxBytes < 0 || q.SoftMaxObjects < 0 || q.SoftMaxBytes < 0 {
+		return errors.New("quota limits must not be negative")
+	}
+	if q.MaxObjects > 0 && q.SoftMaxObjects > q.MaxObjects {
+		return errors.New("SoftMaxObjects must not be above MaxObjects")
+	}
+	if q.MaxBytes > 0 && q.SoftMaxBytes > q.MaxBytes {
+		return errors.New("SoftMaxBytes must not be above MaxBytes")
+	}
+	return nil
+}
+
+// Check decides whether bucket can take objects more objects and bytes more
+// bytes; both are negative when an overwrite makes the bucket smaller.
+// Writes that don't grow the bucket are always allowed, so a bucket over its
+// quota after the limit was lowered can still be cleaned up.
+func Check(bucket *models.Bucket, objects, bytes int64) error {
+	q := bucket.Quota
+	if objects > 0 && q.MaxObjects > 0 && bucket.Objects+objects > q.MaxObjects {
+		return fmt.Errorf("%w: bucket '%s' is limited to %d objects", ErrExceeded, bucket.Name, q.MaxObjects)
+	}
+	if bytes > 0 && q.MaxBytes > 0 && bucket.Bytes+bytes > q.MaxBytes {
+		return fmt.Errorf("%w: bucket '%s' is limited to %d bytes", ErrExceeded, bucket.Name, q.MaxBytes)
+	}
+	return nil
+}
+
+// SoftLimitExceeded reports whether the bucket's usage is over a soft limit
+func SoftLimitExceeded(bucket *models.Bucket) bool {
+	q := bucket.Quota
+	return (q.SoftMaxObjects > 0 && bucket.Objects > q.SoftMaxObjects) ||
+		(q.SoftMaxBytes > 0 && bucket.Bytes > q.SoftMaxBytes)
+}
+
+// Add records a change of the bucket's usage; the caller persists the bucket
+func Add(bucket *models.Bucket, objects, bytes int64) {
+	wasOver := SoftLimitExceeded(bucket)
+	bucket.Objects += objects
+	bucket.Bytes += bytes
+	if !wasOver && SoftLimitExceeded(bucket) {
+		log.Printf("Bucket '%s' exceeded its soft quota: %d objects, %d bytes", bucket.Name, bucket.Objects, bucket.Bytes)
+	}
+}
+
+// Recount derives the usage of every bucket from object metadata, which
+// covers buckets written before usage was tracked, and returns the buckets
+// whose recorded usage was wrong
+func Recount(s *models.Storage) []*models.Bucket {
+	var corrected []*models.Bucket
+	for i := range s.Buckets {
+		bucket := &s.Buckets[i]
+		prefix := utils.DataPath(bucket.Name) + string(filepath.Separator)
+
+		var objects, bytes int64
+		for _, o := range s.Object {
+			if strings.HasPrefix(o.ObjectKey, prefix) {
+				objects++
+				bytes += int64(o.Size)
+			}
+		}
+		if objects != bucket.Objects || bytes != bucket.Bytes {
+			log.Printf("Usage of bucket '%s' corrected to %d objects, %d bytes", bucket.Name, objects, bytes)
+			bucket.Objects, bucket.Bytes = objects, bytes
+			corrected = append(corrected, bucket)
+		}
+	}
+	return corrected
+}
+
+func Status(bucket *models.Bucket) models.BucketQuotaStatus {
+	return models.BucketQuotaStatus{
+		Bucket:            bucket.Name,
+		Objects:           bucket.Objects,
+		Bytes:             bucket.Bytes,
+		Quota:             bucket.Quota,
+		SoftLimitExceeded: SoftLimitExceeded(bucket),
+	}
+}
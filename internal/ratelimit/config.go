@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+const MaxConfigSize = 64 * 1024
+
+// configs caches the limits read from disk by bucket, nil for a bucket
+// without one, so that the middleware does not read a file per request
+var (
+	configsMu sync.Mutex
+	configs   = map[string]*models.RateLimit{}
+)
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "RateLimitConfiguration.xml")
+}
+
+// Load returns the limit of the bucket, or nil if it has none
+func Load(bucket string) (*models.RateLimit, error) {
+	configsMu.Lock()
+	defer configsMu.Unlock()
+	limit, ok := configs[bucket]
+	if !ok {
+		data, err := os.ReadFile(configPath(bucket))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			if limit, err = Parse(data); err != nil {
+				return nil, err
+			}
+		}
+		configs[bucket] = limit
+	}
+	return clone(limit), nil
+}
+
+// clone copies limit, as callers fill in its Bucket
+func clone(limit *models.RateLimit) *models.RateLimit {
+	if limit == nil {
+		return nil
+	}
+	copied := *limit
+	return &copied
+}
+
+func Save(bucket string, limit *models.RateLimit) error {
+	stored := *limit
+	stored.Bucket = ""
+	data, err := xml.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	configsMu.Lock()
+	defer configsMu.Unlock()
+	if err := os.WriteFile(configPath(bucket), data, 0o644); err != nil {
+		delete(configs, bucket)
+		return err
+	}
+	configs[bucket] = &stored
+	return nil
+}
+
+func Delete(bucket string) error {
+	configsMu.Lock()
+	defer configsMu.Unlock()
+	delete(configs, bucket)
+	err := os.Remove(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Forget drops the cached limit of a bucket whose directory is gone
+func Forget(bucket string) {
+	configsMu.Lock()
+	defer configsMu.Unlock()
+	delete(configs, bucket)
+}
+
+func Parse(data []byte) (*models.RateLimit, error) {
+	var limit models.RateLimit
+	if err := xml.Unmarshal(data, &limit); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	if err := Validate(limit); err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// Validate rejects negative limits and a burst without a request rate
+func Validate(limit models.RateLimit) error {
+	if limit.RequestsPerSecond < 0 || math.IsNaN(limit.RequestsPerSecond) || math.IsInf(limit.RequestsPerSecond, 0) ||
+		limit.Burst < 0 || limit.BytesPerSecond < 0 {
+		return errors.New("rate limits must be finite and not negative")
+	}
+	if limit.Burst > 0 && limit.RequestsPerSecond == 0 {
+		return errors.New("Burst needs a RequestsPerSecond")
+	}
+	return nil
+}
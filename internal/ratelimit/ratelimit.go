@@ -0,0 +1,328 @@
+package ratelimit
+
+import (
+	"A3S/internal/auth"
+	"A3S/internal/models"
+	"A3S/internal/s3err"
+	"A3S/internal/storagelock"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is the limit of every client, set from the --rate-limit-* flags.
+// A client is the access key a request is signed with, the user of a client
+// certificate, or the source IP of anonymous requests
+var Client models.RateLimit
+
+// limiters that have been idle this long and owe nothing are dropped
+const idleAfter = 10 * time.Minute
+
+var (
+	mu        sync.Mutex
+	clients   = map[string]*limiter{}
+	buckets   = map[string]*limiter{}
+	lastSweep time.Time
+)
+
+// tokenBucket refills at rate tokens per second up to burst
+type tokenBucket struct {
+	rate, burst, tokens float64
+	last                time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait is how long until the bucket holds n tokens
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// limiter holds the request and bandwidth budget of one client or bucket.
+// Bodies wait for bandwidth before their bytes move, except while the
+// request holds the storage lock, where they go into debt rather than stall
+// everybody; requests are refused until such a debt is paid
+type limiter struct {
+	limit    models.RateLimit
+	requests tokenBucket
+	bytes    tokenBucket
+	used     time.Time
+}
+
+// configure applies limit, starting with full buckets when it changed
+func (l *limiter) configure(limit models.RateLimit, now time.Time) {
+	l.used = now
+	if l.limit == limit && !l.requests.last.IsZero() {
+		l.requests.refill(now)
+		l.bytes.refill(now)
+		return
+	}
+
+	burst := float64(limit.Burst)
+	if burst == 0 {
+		burst = math.Max(1, math.Ceil(limit.RequestsPerSecond))
+	}
+	rate := float64(limit.BytesPerSecond)
+	l.limit = limit
+	l.requests = tokenBucket{rate: limit.RequestsPerSecond, burst: burst, tokens: burst, last: now}
+	l.bytes = tokenBucket{rate: rate, burst: rate, tokens: rate, last: now}
+}
+
+// wait is how long until the limiter lets another request through
+func (l *limiter) wait() time.Duration {
+	var wait time.Duration
+	if l.limit.RequestsPerSecond > 0 {
+		wait = l.requests.wait(1)
+	}
+	// a bandwidth debt has to be paid back to zero
+	if l.limit.BytesPerSecond > 0 {
+		wait = max(wait, l.bytes.wait(0))
+	}
+	return wait
+}
+
+func (l *limiter) idle(now time.Time) bool {
+	l.requests.refill(now)
+	l.bytes.refill(now)
+	return now.Sub(l.used) > idleAfter && l.requests.tokens >= l.requests.burst && l.bytes.tokens >= l.bytes.burst
+}
+
+func enabled(limit models.RateLimit) bool {
+	return limit.RequestsPerSecond > 0 || limit.BytesPerSecond > 0
+}
+
+// lookup returns the limiter of key in m, creating it as needed; mu must be held
+func lookup(m map[string]*limiter, key string, limit models.RateLimit, now time.Time) *limiter {
+	l, ok := m[key]
+	if !ok {
+		l = &limiter{}
+		m[key] = l
+	}
+	l.configure(limit, now)
+	return l
+}
+
+// sweep drops idle limiters once a minute; mu must be held
+func sweep(now time.Time) {
+	if now.Sub(lastSweep) < time.Minute {
+		return
+	}
+	lastSweep = now
+	for _, m := range []map[string]*limiter{clients, buckets} {
+		for key, l := range m {
+			if l.idle(now) {
+				delete(m, key)
+			}
+		}
+	}
+}
+
+// Middleware refuses requests over the limit of their client or bucket with
+// a 503 SlowDown and a Retry-After header, and holds request and response
+// bodies to their bandwidth. It runs after authentication, which tells who
+// the client is.
+func Middleware(s *models.Storage, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var bucketLimit *models.RateLimit
+		if name := bucketName(s, r); name != "" {
+			limit, err := Load(name)
+			if err != nil {
+				log.Printf("Error reading rate limit of bucket '%s': %v", name, err)
+			} else if limit != nil && enabled(*limit) {
+				limit.Bucket = name
+				bucketLimit = limit
+			}
+		}
+
+		now := time.Now()
+		var limiters []*limiter
+		mu.Lock()
+		sweep(now)
+		if enabled(Client) {
+			limiters = append(limiters, lookup(clients, clientKey(r), Client, now))
+		}
+		if bucketLimit != nil {
+			limiters = append(limiters, lookup(buckets, bucketLimit.Bucket, *bucketLimit, now))
+		}
+
+		var wait time.Duration
+		for _, l := range limiters {
+			wait = max(wait, l.wait())
+		}
+		if wait == 0 {
+			for _, l := range limiters {
+				if l.limit.RequestsPerSecond > 0 {
+					l.requests.tokens--
+				}
+			}
+		}
+		mu.Unlock()
+
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			s3err.Write(w, r, s3err.SlowDown, "")
+			return
+		}
+		if len(limiters) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &body{ReadCloser: r.Body, r: r, limiters: limiters}
+		}
+		next.ServeHTTP(&writer{ResponseWriter: w, r: r, limiters: limiters}, r)
+	})
+}
+
+// exempt lets health probes and root through, so that orchestrators keep
+// seeing the server and an administrator can always lift a limit
+func exempt(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/_healthz", "/_readyz", "/_version":
+		return true
+	}
+	return auth.Caller(r) == auth.RootUser
+}
+
+// bucketName returns the existing bucket the request is addressed to
+func bucketName(s *models.Storage, r *http.Request) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	for _, b := range s.Buckets {
+		if b.Name == name {
+			return name
+		}
+	}
+	return ""
+}
+
+func clientKey(r *http.Request) string {
+	if key := auth.AccessKey(r); key != "" {
+		return "key:" + key
+	}
+	if user := auth.Caller(r); user != "" {
+		return "user:" + user
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// charge takes n bytes from the bandwidth of every limiter
+func charge(limiters []*limiter, n int) {
+	if n == 0 {
+		return
+	}
+	now := time.Now()
+	mu.Lock()
+	defer mu.Unlock()
+	for _, l := range limiters {
+		if l.limit.BytesPerSecond > 0 {
+			l.bytes.refill(now)
+			l.bytes.tokens -= float64(n)
+			l.used = now
+		}
+	}
+}
+
+// throttle waits until every limiter has the bandwidth for up to n bytes
+// and returns how many may move now, at most a second's worth. A request
+// holding the storage lock moves all n at once and only gets charged.
+func throttle(r *http.Request, limiters []*limiter, n int) (int, error) {
+	if n == 0 || storagelock.Held(r) {
+		return n, nil
+	}
+	for {
+		now := time.Now()
+		mu.Lock()
+		allowed := float64(n)
+		for _, l := range limiters {
+			if l.limit.BytesPerSecond > 0 {
+				l.bytes.refill(now)
+				allowed = math.Min(allowed, l.bytes.burst)
+			}
+		}
+		var wait time.Duration
+		for _, l := range limiters {
+			if l.limit.BytesPerSecond > 0 {
+				wait = max(wait, l.bytes.wait(allowed))
+			}
+		}
+		mu.Unlock()
+
+		if wait == 0 {
+			return int(allowed), nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return 0, r.Context().Err()
+		}
+	}
+}
+
+type body struct {
+	io.ReadCloser
+	r        *http.Request
+	limiters []*limiter
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	allowed, err := throttle(b.r, b.limiters, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n, err := b.ReadCloser.Read(p[:allowed])
+	charge(b.limiters, n)
+	return n, err
+}
+
+type writer struct {
+	http.ResponseWriter
+	r        *http.Request
+	limiters []*limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		allowed, err := throttle(w.r, w.limiters, len(p))
+		if err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(p[:allowed])
+		charge(w.limiters, n)
+		written += n
+		p = p[n:]
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Unwrap lets http.ResponseController reach Flush for event streams
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,162 @@
+package replication
+
+import (
+	"A3S/internal/bucketname"
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const MaxConfigSize = 64 * 1024
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "ReplicationConfiguration.xml")
+}
+
+// Load returns the bucket's replication configuration, or nil if there is none
+func Load(bucket string) (*models.ReplicationConfiguration, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Save(bucket string, config *models.ReplicationConfiguration) error {
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the file holds the destination secrets
+	return os.WriteFile(configPath(bucket), data, 0o600)
+}
+
+func Delete(bucket string) error {
+	err := os.Remove(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func Parse(data []byte) (*models.ReplicationConfiguration, error) {
+	var config models.ReplicationConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	if len(config.Rules) == 0 {
+		return nil, errors.New("at least one Rule is required")
+	}
+
+	ids := map[string]bool{}
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.ID != "" {
+			if ids[rule.ID] {
+				return nil, fmt.Errorf("rule %d: duplicate ID %q", i, rule.ID)
+			}
+			ids[rule.ID] = true
+		}
+		if rule.Status != "Enabled" && rule.Status != "Disabled" {
+			return nil, fmt.Errorf("rule %d: Status must be Enabled or Disabled", i)
+		}
+		if rule.Filter != nil {
+			for _, tag := range rule.Filter.Tags {
+				if tag.Key == "" {
+					return nil, fmt.Errorf("rule %d: filter Tag needs a Key", i)
+				}
+			}
+		}
+
+		dest := &rule.Destination
+		// the ARN form of S3 is accepted as well as a plain name
+		dest.Bucket = strings.TrimPrefix(dest.Bucket, "arn:aws:s3:::")
+		if err := checkDestination(*dest); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+	}
+
+	return &config, nil
+}
+
+// checkDestination only lets a rule name a destination the operator allowed
+// with --replication-dirs or --replication-endpoints, as anyone who may
+// configure replication would otherwise write files anywhere on the server
+// or send requests to any address it can reach
+func checkDestination(dest models.ReplicationDestination) error {
+	if _, err := bucketname.Validate(dest.Bucket); err != nil {
+		return fmt.Errorf("Destination Bucket: %v", err)
+	}
+
+	switch {
+	case dest.Endpoint != "" && dest.Directory != "":
+		return errors.New("Destination takes either an Endpoint or a Directory")
+	case dest.Endpoint != "":
+		endpoint := strings.TrimSuffix(dest.Endpoint, "/")
+		if !slices.ContainsFunc(utils.SplitList(*utils.ReplicationEndpoints), func(allowed string) bool {
+			return strings.TrimSuffix(allowed, "/") == endpoint
+		}) {
+			return fmt.Errorf("Endpoint %q is not one of --replication-endpoints", dest.Endpoint)
+		}
+		if dest.AccessKey == "" || dest.SecretKey == "" {
+			return errors.New("an Endpoint needs an AccessKey and a SecretKey")
+		}
+	case dest.Directory != "":
+		dir := filepath.Clean(dest.Directory)
+		if !slices.ContainsFunc(utils.SplitList(*utils.ReplicationDirs), func(allowed string) bool {
+			return filepath.Clean(allowed) == dir
+		}) {
+			return fmt.Errorf("Directory %q is not one of --replication-dirs", dest.Directory)
+		}
+	default:
+		return errors.New("Destination needs an Endpoint or a Directory")
+	}
+	return nil
+}
+
+// Redacted is config as it can be shown to clients, without the secret keys
+func Redacted(config *models.ReplicationConfiguration) *models.ReplicationConfiguration {
+	redacted := *config
+	redacted.Rules = append([]models.ReplicationRule(nil), config.Rules...)
+	for i := range redacted.Rules {
+		redacted.Rules[i].Destination.SecretKey = ""
+	}
+	return &redacted
+}
+
+// ruleFor returns the first enabled rule that covers an object, or nil
+func ruleFor(config *models.ReplicationConfiguration, key, tags string) *models.ReplicationRule {
+	objectTags, _ := url.ParseQuery(tags)
+	for i, rule := range config.Rules {
+		if rule.Status != "Enabled" {
+			continue
+		}
+		if rule.Filter != nil && !filterMatches(rule.Filter, key, objectTags) {
+			continue
+		}
+		return &config.Rules[i]
+	}
+	return nil
+}
+
+func filterMatches(filter *models.ReplicationFilter, key string, tags url.Values) bool {
+	if !strings.HasPrefix(key, filter.Prefix) {
+		return false
+	}
+	for _, tag := range filter.Tags {
+		if !tags.Has(tag.Key) || tags.Get(tag.Key) != tag.Value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package replication
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	pollInterval = time.Second
+	maxBackoff   = time.Hour
+)
+
+// MaxAttempts is how often an object is sent before it is marked FAILED,
+// set from --delivery-attempts
+var MaxAttempts = 10
+
+// pending copies survive restarts: each one is a file in queueDir
+func queueDir() string {
+	return utils.DataPath(".replication")
+}
+
+func failedDir() string {
+	return filepath.Join(queueDir(), "failed")
+}
+
+var (
+	stopCh = make(chan struct{})
+	doneCh = make(chan struct{})
+)
+
+type task struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// Version is the modification time of the object when it was queued,
+	// a task for an object overwritten since then is dropped
+	Version     time.Time                     `json:"version"`
+	Rule        string                        `json:"rule,omitempty"`
+	Destination models.ReplicationDestination `json:"destination"`
+	Attempts    int                           `json:"attempts"`
+	NextAttempt time.Time                     `json:"nextAttempt"`
+	LastError   string                        `json:"lastError,omitempty"`
+}
+
+func enqueue(t *task) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	// names sort in the order objects were written
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), hex.EncodeToString(suffix))
+	t.NextAttempt = time.Now()
+	return saveTask(filepath.Join(queueDir(), name), t)
+}
+
+func saveTask(path string, t *task) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func replicateDue(s *models.Storage) {
+	// ReadDir sorts by name, so the oldest writes go first
+	entries, err := os.ReadDir(queueDir())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error reading replication queue: %v", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		if stopping() {
+			return
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(queueDir(), entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var t task
+		if err := json.Unmarshal(data, &t); err != nil {
+			log.Printf("Dropping unreadable replication task %s: %v", entry.Name(), err)
+			os.Remove(path)
+			continue
+		}
+		if time.Now().Before(t.NextAttempt) {
+			continue
+		}
+
+		s.Lock()
+		object := currentObject(s, &t)
+		s.Unlock()
+		if object == nil {
+			// deleted or overwritten, a newer task takes care of the latter
+			os.Remove(path)
+			continue
+		}
+
+		err = replicate(object, &t)
+		if err == nil {
+			log.Printf("Object '%s/%s' replicated to bucket '%s'", t.Bucket, t.Key, t.Destination.Bucket)
+			s.Lock()
+			setStatus(s, &t, StatusCompleted)
+			s.Unlock()
+			os.Remove(path)
+			continue
+		}
+
+		t.Attempts++
+		t.LastError = err.Error()
+		if t.Attempts >= MaxAttempts {
+			log.Printf("Giving up on replicating '%s/%s' after %d attempts: %v", t.Bucket, t.Key, t.Attempts, err)
+			s.Lock()
+			setStatus(s, &t, StatusFailed)
+			s.Unlock()
+			if err := saveTask(filepath.Join(failedDir(), entry.Name()), &t); err == nil {
+				os.Remove(path)
+			}
+			continue
+		}
+
+		t.NextAttempt = time.Now().Add(backoff(t.Attempts))
+		log.Printf("Replicating '%s/%s' failed (attempt %d), retrying at %s: %v", t.Bucket, t.Key, t.Attempts, t.NextAttempt.Format(time.RFC3339), err)
+		if err := saveTask(path, &t); err != nil {
+			log.Printf("Error updating replication task %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+// Stop waits for the copy in progress to finish; objects not yet copied
+// stay queued for the next start
+func Stop(ctx context.Context) error {
+	close(stopCh)
+	select {
+	case <-doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func stopping() bool {
+	select {
+	case <-stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff doubles the wait after every failed attempt, starting at one second
+func backoff(attempts int) time.Duration {
+	wait := time.Second << (attempts - 1)
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
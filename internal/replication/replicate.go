@@ -0,0 +1,223 @@
+package replication
+
+import (
+	"A3S/internal/auth"
+	"A3S/internal/blob"
+	"A3S/internal/csv"
+	"A3S/internal/events"
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// values of x-amz-replication-status
+const (
+	StatusPending   = "PENDING"
+	StatusCompleted = "COMPLETED"
+	StatusFailed    = "FAILED"
+	// StatusReplica marks objects written by another server's replication,
+	// they are never replicated again so that two-way setups do not loop
+	StatusReplica = "REPLICA"
+)
+
+// uploads may take long, only a server that stops answering is given up on
+var client = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: time.Minute,
+	},
+}
+
+// Start queues new objects of buckets with a replication configuration and
+// copies them in the background until Stop is called
+func Start(s *models.Storage) {
+	events.Listen(func(e events.Event) {
+		if strings.HasPrefix(e.Name, "s3:ObjectCreated:") {
+			queueObject(s, e.Bucket, e.Key)
+		}
+	})
+
+	go func() {
+		defer close(doneCh)
+		for {
+			replicateDue(s)
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+	}()
+}
+
+// Replicator reports whether the caller may mark its writes as replicas:
+// root, or the user named by --replication-user
+func Replicator(r *http.Request) bool {
+	caller := auth.Caller(r)
+	return caller == auth.RootUser || (caller != "" && caller == *utils.ReplicationUser)
+}
+
+// queueObject runs on the request that wrote the object, which holds the
+// storage lock
+func queueObject(s *models.Storage, bucket, key string) {
+	objectPath := utils.DataPath(bucket, key)
+	index := -1
+	for i, o := range s.Object {
+		if o.ObjectKey == objectPath {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return
+	}
+	object := &s.Object[index]
+	// customer-provided keys are not kept, so such objects cannot be read back
+	if object.ReplicationStatus == StatusReplica || object.CustomerKeyFingerprint != "" {
+		return
+	}
+
+	config, err := Load(bucket)
+	if err != nil {
+		log.Printf("Error reading replication configuration of bucket '%s': %v", bucket, err)
+		return
+	}
+	if config == nil {
+		return
+	}
+	rule := ruleFor(config, key, object.Tags)
+	if rule == nil {
+		return
+	}
+
+	t := &task{
+		Bucket:      bucket,
+		Key:         key,
+		Version:     object.LastModified,
+		Rule:        rule.ID,
+		Destination: rule.Destination,
+	}
+	if err := enqueue(t); err != nil {
+		log.Printf("Error queueing replication of '%s/%s': %v", bucket, key, err)
+		return
+	}
+	object.ReplicationStatus = StatusPending
+	csv.CSVUpdateObjectMetaData(object, bucket)
+}
+
+// currentObject returns a copy of the object t was queued for, or nil if it is
+// gone or has been overwritten since
+func currentObject(s *models.Storage, t *task) *models.Object {
+	objectPath := utils.DataPath(t.Bucket, t.Key)
+	for _, o := range s.Object {
+		if o.ObjectKey == objectPath {
+			// the metadata file keeps whole seconds only
+			if o.LastModified.Unix() != t.Version.Unix() {
+				return nil
+			}
+			return &o
+		}
+	}
+	return nil
+}
+
+func setStatus(s *models.Storage, t *task, status string) {
+	objectPath := utils.DataPath(t.Bucket, t.Key)
+	for i, o := range s.Object {
+		if o.ObjectKey == objectPath && o.LastModified.Unix() == t.Version.Unix() {
+			s.Object[i].ReplicationStatus = status
+			csv.CSVUpdateObjectMetaData(&s.Object[i], t.Bucket)
+			return
+		}
+	}
+}
+
+func replicate(object *models.Object, t *task) error {
+	// the allowed destinations may have changed since the task was queued
+	if err := checkDestination(t.Destination); err != nil {
+		return err
+	}
+
+	body, size, err := blob.Open(object, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if t.Destination.Directory != "" {
+		return writeFile(t.Destination, t.Key, body)
+	}
+	return upload(t.Destination, t.Key, object, body, size)
+}
+
+// upload PUTs the object to the destination server; the replica keeps the
+// ACL and tags and is marked as a replica there
+func upload(dest models.ReplicationDestination, key string, object *models.Object, body io.Reader, size int64) error {
+	target, err := url.Parse(dest.Endpoint)
+	if err != nil {
+		return err
+	}
+	target.Path = path.Join("/", target.Path, dest.Bucket, key)
+
+	req, err := http.NewRequest(http.MethodPut, target.String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("x-amz-replication-status", StatusReplica)
+	if object.ACL != "" {
+		req.Header.Set("x-amz-acl", object.ACL)
+	}
+	if object.Tags != "" {
+		req.Header.Set("x-amz-tagging", object.Tags)
+	}
+	auth.SignRequest(req, dest.AccessKey, dest.SecretKey, time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("destination answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// writeFile stores the object as a plain file under Directory/Bucket/key,
+// replacing an older copy only once the new one is complete
+func writeFile(dest models.ReplicationDestination, key string, body io.Reader) error {
+	target := filepath.Join(dest.Directory, dest.Bucket, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(target), ".replica-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), target)
+}
@@ -0,0 +1,97 @@
+package s3err
+
+import (
+	"A3S/internal/accesslog"
+	"A3S/internal/models"
+	"encoding/xml"
+	"log"
+	"net/http"
+)
+
+// Error is an entry of the catalogue: an S3 error code with the HTTP status
+// and the message S3 answers it with
+type Error struct {
+	Code           string
+	HTTPStatusCode int
+	Description    string
+}
+
+// the codes are those of S3 where it has one, so that SDKs recognise them;
+// the admin API uses the IAM codes for users and keys
+var (
+	AccessDenied                       = Error{"AccessDenied", http.StatusForbidden, "Access Denied"}
+	AccessForbidden                    = Error{"AccessForbidden", http.StatusForbidden, "CORSResponse: This CORS request is not allowed."}
+	AuthorizationHeaderMalformed       = Error{"AuthorizationHeaderMalformed", http.StatusBadRequest, "The authorization header you provided is invalid."}
+	AuthorizationQueryParametersError  = Error{"AuthorizationQueryParametersError", http.StatusBadRequest, "The authorization query parameters you provided are invalid."}
+	BucketAlreadyOwnedByYou            = Error{"BucketAlreadyOwnedByYou", http.StatusConflict, "Your previous request to create the named bucket succeeded and you already own it."}
+	BucketAlreadyExists                = Error{"BucketAlreadyExists", http.StatusConflict, "The requested bucket name is not available. Please select a different name and try again."}
+	BucketNotEmpty                     = Error{"BucketNotEmpty", http.StatusConflict, "The bucket you tried to delete is not empty."}
+	EntityAlreadyExists                = Error{"EntityAlreadyExists", http.StatusConflict, "The entity already exists."}
+	EntityTooLarge                     = Error{"EntityTooLarge", http.StatusBadRequest, "Your proposed upload exceeds the maximum allowed object size."}
+	ExpiredToken                       = Error{"AccessDenied", http.StatusForbidden, "Request has expired."}
+	InternalError                      = Error{"InternalError", http.StatusInternalServerError, "We encountered an internal error. Please try again."}
+	InvalidAccessKeyID                 = Error{"InvalidAccessKeyId", http.StatusForbidden, "The AWS access key Id you provided does not exist in our records."}
+	InvalidArgument                    = Error{"InvalidArgument", http.StatusBadRequest, "Invalid Argument."}
+	InvalidBucketName                  = Error{"InvalidBucketName", http.StatusBadRequest, "The specified bucket is not valid."}
+	InvalidBucketState                 = Error{"InvalidBucketState", http.StatusConflict, "The request is not valid with the current state of the bucket."}
+	InvalidEncryptionAlgorithm         = Error{"InvalidEncryptionAlgorithmError", http.StatusBadRequest, "The encryption request you specified is not valid."}
+	InvalidRequest                     = Error{"InvalidRequest", http.StatusBadRequest, "Invalid Request."}
+	InvalidTag                         = Error{"InvalidTag", http.StatusBadRequest, "The tag provided was not a valid tag."}
+	InvalidTargetBucketForLogging      = Error{"InvalidTargetBucketForLogging", http.StatusBadRequest, "The target bucket for logging does not exist."}
+	KeyTooLong                         = Error{"KeyTooLongError", http.StatusBadRequest, "Your key is too long."}
+	MalformedACL                       = Error{"MalformedACLError", http.StatusBadRequest, "The ACL you provided was not well-formed or did not validate against our published schema."}
+	MalformedPolicy                    = Error{"MalformedPolicy", http.StatusBadRequest, "Policy has invalid resource."}
+	MalformedXML                       = Error{"MalformedXML", http.StatusBadRequest, "The XML you provided was not well-formed or did not validate against our published schema."}
+	MaxMessageLengthExceeded           = Error{"MaxMessageLengthExceeded", http.StatusBadRequest, "Your request was too big."}
+	MethodNotAllowed                   = Error{"MethodNotAllowed", http.StatusMethodNotAllowed, "The specified method is not allowed against this resource."}
+	NoSuchBucket                       = Error{"NoSuchBucket", http.StatusNotFound, "The specified bucket does not exist."}
+	NoSuchBucketPolicy                 = Error{"NoSuchBucketPolicy", http.StatusNotFound, "The bucket policy does not exist."}
+	NoSuchCompressionConfiguration     = Error{"NoSuchCompressionConfiguration", http.StatusNotFound, "The specified bucket does not have a compression configuration."}
+	NoSuchCORSConfiguration            = Error{"NoSuchCORSConfiguration", http.StatusNotFound, "The CORS configuration does not exist."}
+	NoSuchEntity                       = Error{"NoSuchEntity", http.StatusNotFound, "The request was rejected because it referenced an entity that does not exist."}
+	NoSuchKey                          = Error{"NoSuchKey", http.StatusNotFound, "The specified key does not exist."}
+	NoSuchObjectLockConfiguration      = Error{"NoSuchObjectLockConfiguration", http.StatusNotFound, "The specified object does not have a ObjectLock configuration."}
+	NoSuchRateLimit                    = Error{"NoSuchRateLimit", http.StatusNotFound, "The specified bucket does not have a rate limit."}
+	NoSuchWebsiteConfiguration         = Error{"NoSuchWebsiteConfiguration", http.StatusNotFound, "The specified bucket does not have a website configuration."}
+	NotImplemented                     = Error{"NotImplemented", http.StatusNotImplemented, "A header you provided implies functionality that is not implemented."}
+	ObjectLockConfigurationNotFound    = Error{"ObjectLockConfigurationNotFoundError", http.StatusNotFound, "Object Lock configuration does not exist for this bucket."}
+	QuotaExceeded                      = Error{"QuotaExceeded", http.StatusForbidden, "The bucket quota has been exceeded."}
+	ReplicationConfigurationNotFound   = Error{"ReplicationConfigurationNotFoundError", http.StatusNotFound, "The replication configuration was not found."}
+	RequestTimeTooSkewed               = Error{"RequestTimeTooSkewed", http.StatusForbidden, "The difference between the request time and the current time is too large."}
+	RequestTimeout                     = Error{"RequestTimeout", http.StatusBadRequest, "Your socket connection to the server was not read from or written to within the timeout period."}
+	ServerSideEncryptionConfigNotFound = Error{"ServerSideEncryptionConfigurationNotFoundError", http.StatusNotFound, "The server side encryption configuration was not found."}
+	SignatureDoesNotMatch              = Error{"SignatureDoesNotMatch", http.StatusForbidden, "The request signature we calculated does not match the signature you provided. Check your key and signing method."}
+	SlowDown                           = Error{"SlowDown", http.StatusServiceUnavailable, "Please reduce your request rate."}
+	XAmzContentSHA256Mismatch          = Error{"XAmzContentSHA256Mismatch", http.StatusBadRequest, "The provided 'x-amz-content-sha256' header does not match what was computed."}
+)
+
+// Write answers r with the S3 error document of e; message replaces the
+// catalogue's description when it tells the client more, like which
+// argument was wrong
+func Write(w http.ResponseWriter, r *http.Request, e Error, message string) {
+	if message == "" {
+		message = e.Description
+	}
+	if entry := accesslog.FromContext(r.Context()); entry != nil {
+		entry.ErrorCode = e.Code
+	}
+
+	// the access log middleware has already put the request IDs on w
+	data, err := xml.MarshalIndent(models.ErrorResponse{
+		Code:      e.Code,
+		Message:   message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get(accesslog.RequestIDHeader),
+		HostID:    w.Header().Get(accesslog.HostIDHeader),
+	}, "", "  ")
+	if err != nil {
+		log.Printf("Error generating XML response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(e.HTTPStatusCode)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
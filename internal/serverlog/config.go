@@ -0,0 +1,60 @@
+package serverlog
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const MaxConfigSize = 64 * 1024
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "LoggingConfiguration.xml")
+}
+
+// Load returns where the bucket's access log goes, or nil if it is not logged
+func Load(bucket string) (*models.LoggingEnabled, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return status.LoggingEnabled, nil
+}
+
+// Save stores status; like S3, a status without LoggingEnabled turns logging off
+func Save(bucket string, status *models.BucketLoggingStatus) error {
+	if status.LoggingEnabled == nil {
+		err := os.Remove(configPath(bucket))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := xml.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(bucket), data, 0o644)
+}
+
+func Parse(data []byte) (*models.BucketLoggingStatus, error) {
+	var status models.BucketLoggingStatus
+	if err := xml.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	if status.LoggingEnabled != nil && status.LoggingEnabled.TargetBucket == "" {
+		return nil, errors.New("LoggingEnabled needs a TargetBucket")
+	}
+	return &status, nil
+}
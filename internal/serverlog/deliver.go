@@ -0,0 +1,205 @@
+package serverlog
+
+import (
+	"A3S/internal/accesslog"
+	"A3S/internal/models"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBuffered makes a busy bucket's log delivered early
+const maxBuffered = 1 << 20
+
+// FlushInterval is how long access records are buffered before they are
+// written into the target bucket, set from --log-flush-interval
+var FlushInterval = 5 * time.Minute
+
+// StoreFunc writes an object through the regular object write path
+type StoreFunc func(s *models.Storage, bucket, key, contentType string, body io.Reader) error
+
+// pending holds the records of one source bucket bound for one target
+type pending struct {
+	target string
+	prefix string
+	lines  bytes.Buffer
+}
+
+var (
+	mu      sync.Mutex
+	buffers = map[string]*pending{}
+	flushCh = make(chan struct{}, 1)
+	stopCh  = make(chan struct{})
+	doneCh  = make(chan struct{})
+
+	storage *models.Storage
+	store   StoreFunc
+)
+
+// Start records the requests of buckets with logging enabled and delivers
+// them into their target buckets in the background until Stop is called
+func Start(s *models.Storage, storeFn StoreFunc) {
+	storage, store = s, storeFn
+	accesslog.Listen(record)
+
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			case <-flushCh:
+			}
+			Flush()
+		}
+	}()
+}
+
+// Stop ends the background delivery and writes out what is still buffered;
+// it is called once no more requests are served
+func Stop(ctx context.Context) error {
+	close(stopCh)
+	select {
+	case <-doneCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	Flush()
+	return nil
+}
+
+func record(entry *accesslog.Entry) {
+	if entry.Bucket == "" || strings.HasPrefix(entry.Bucket, "_") {
+		return
+	}
+	target, err := Load(entry.Bucket)
+	if err != nil {
+		log.Printf("Error reading logging configuration of bucket '%s': %v", entry.Bucket, err)
+		return
+	}
+	if target == nil {
+		return
+	}
+
+	// records are taken once the request has let go of the storage lock
+	owner := ""
+	storage.Lock()
+	for _, b := range storage.Buckets {
+		if b.Name == entry.Bucket {
+			owner = b.Owner
+			break
+		}
+	}
+	storage.Unlock()
+	line := format(owner, entry)
+
+	mu.Lock()
+	defer mu.Unlock()
+	key := entry.Bucket + "\x00" + target.TargetBucket + "\x00" + target.TargetPrefix
+	p := buffers[key]
+	if p == nil {
+		p = &pending{target: target.TargetBucket, prefix: target.TargetPrefix}
+		buffers[key] = p
+	}
+	p.lines.WriteString(line)
+	if p.lines.Len() >= maxBuffered {
+		select {
+		case flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes all buffered records into their target buckets, one object
+// per source bucket and target
+func Flush() {
+	mu.Lock()
+	current := buffers
+	buffers = map[string]*pending{}
+	mu.Unlock()
+
+	for _, p := range current {
+		key := p.prefix + objectName(time.Now())
+		storage.Lock()
+		err := store(storage, p.target, key, "text/plain", bytes.NewReader(p.lines.Bytes()))
+		storage.Unlock()
+		if err != nil {
+			log.Printf("Dropping access log for bucket '%s': %v", p.target, err)
+			continue
+		}
+		log.Printf("Access log '%s' delivered to bucket '%s'", key, p.target)
+	}
+}
+
+// objectName follows S3: TargetPrefixYYYY-mm-DD-HH-MM-SS-UniqueString
+func objectName(t time.Time) string {
+	unique := make([]byte, 8)
+	rand.Read(unique)
+	return t.UTC().Format("2006-01-02-15-04-05") + "-" + strings.ToUpper(hex.EncodeToString(unique))
+}
+
+// format renders entry in the S3 server access log format
+func format(owner string, entry *accesslog.Entry) string {
+	sigVersion := "-"
+	if entry.AuthType != "" {
+		sigVersion = "SigV4"
+	}
+	fields := []string{
+		dash(owner),
+		entry.Bucket,
+		entry.Time.UTC().Format("[02/Jan/2006:15:04:05 -0700]"),
+		dash(entry.RemoteIP),
+		dash(entry.Caller),
+		entry.RequestID,
+		entry.Operation,
+		dash(entry.Key),
+		quote(entry.Method + " " + entry.RequestURI + " " + entry.Proto),
+		strconv.Itoa(entry.Status),
+		dash(entry.ErrorCode),
+		count(entry.BytesOut),
+		"-",
+		strconv.FormatInt(entry.Latency.Milliseconds(), 10),
+		"-",
+		quote(dash(entry.Referer)),
+		quote(dash(entry.UserAgent)),
+		"-",
+		entry.HostID,
+		sigVersion,
+		"-",
+		dash(entry.AuthType),
+		dash(entry.Host),
+		dash(entry.TLSVersion),
+		"-",
+		"-",
+	}
+	return strings.Join(fields, " ") + "\n"
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func count(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func quote(s string) string {
+	return fmt.Sprintf("%q", s)
+}
@@ -0,0 +1,89 @@
+package sse
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const MaxConfigSize = 64 * 1024
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "EncryptionConfiguration.xml")
+}
+
+// LoadBucketDefault returns the bucket's default encryption, or nil if objects
+// are stored in plaintext unless the request asks otherwise
+func LoadBucketDefault(bucket string) (*models.ServerSideEncryptionConfiguration, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+func SaveBucketDefault(bucket string, config *models.ServerSideEncryptionConfiguration) error {
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(bucket), data, 0o644)
+}
+
+func DeleteBucketDefault(bucket string) error {
+	err := os.Remove(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func ParseConfig(data []byte) (*models.ServerSideEncryptionConfiguration, error) {
+	var config models.ServerSideEncryptionConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+	if len(config.Rules) != 1 {
+		return nil, errors.New("exactly one Rule is required")
+	}
+	if algorithm := config.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm; algorithm != AES256 {
+		return nil, fmt.Errorf("unsupported SSEAlgorithm %q, only %s is available", algorithm, AES256)
+	}
+	return &config, nil
+}
+
+// Resolve decides how a new object is encrypted from its x-amz-server-side-encryption
+// header, a customer-provided key and the bucket default; "" means plaintext
+func Resolve(bucket, header string, customerKey *CustomerKey) (string, error) {
+	if customerKey != nil {
+		if header != "" {
+			return "", errors.New("x-amz-server-side-encryption cannot be combined with a customer-provided key")
+		}
+		return AES256, nil
+	}
+
+	if header != "" {
+		if header != AES256 {
+			return "", fmt.Errorf("unsupported server-side encryption %q", header)
+		}
+	} else {
+		config, err := LoadBucketDefault(bucket)
+		if err != nil {
+			return "", err
+		}
+		if config == nil {
+			return "", nil
+		}
+	}
+
+	if !Enabled() {
+		return "", ErrNoMasterKey
+	}
+	return AES256, nil
+}
@@ -0,0 +1,118 @@
+package sse
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// header prefixes of customer-provided keys (SSE-C) for the object itself and
+// for the source of a copy
+const (
+	CustomerHeaderPrefix   = "x-amz-server-side-encryption-customer-"
+	CopySourceHeaderPrefix = "x-amz-copy-source-server-side-encryption-customer-"
+)
+
+var (
+	ErrCustomerKeyRequired    = errors.New("the object was stored using a customer-provided key, which must be supplied to access it")
+	ErrCustomerKeyMismatch    = errors.New("the customer-provided key does not match the key the object was stored with")
+	ErrCustomerKeyNotExpected = errors.New("the object was not stored using a customer-provided key")
+)
+
+// CustomerKey is an SSE-C key taken from request headers; it is only ever
+// held in memory for the duration of the request
+type CustomerKey struct {
+	Key    []byte
+	KeyMD5 string
+}
+
+// ParseCustomerKey reads the algorithm, key and key-MD5 headers starting with
+// prefix; it returns nil when none of them is present
+func ParseCustomerKey(header http.Header, prefix string) (*CustomerKey, error) {
+	algorithm := header.Get(prefix + "algorithm")
+	encodedKey := header.Get(prefix + "key")
+	keyMD5 := header.Get(prefix + "key-MD5")
+	if algorithm == "" && encodedKey == "" && keyMD5 == "" {
+		return nil, nil
+	}
+
+	if algorithm != AES256 {
+		return nil, fmt.Errorf("%salgorithm must be %s", prefix, AES256)
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%skey must be a base64 encoded 256-bit key", prefix)
+	}
+	digest := md5.Sum(key)
+	if keyMD5 != base64.StdEncoding.EncodeToString(digest[:]) {
+		return nil, fmt.Errorf("%skey-MD5 does not match the key", prefix)
+	}
+	return &CustomerKey{Key: key, KeyMD5: keyMD5}, nil
+}
+
+// Fingerprint returns a salted HMAC of the key, which is all we keep to
+// recognise the key on later requests
+func (k *CustomerKey) Fingerprint() (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt) + ":" + hex.EncodeToString(k.mac(salt)), nil
+}
+
+// Matches checks the key against a fingerprint made by Fingerprint
+func (k *CustomerKey) Matches(fingerprint string) bool {
+	encodedSalt, encodedMAC, found := strings.Cut(fingerprint, ":")
+	if !found {
+		return false
+	}
+	salt, err := hex.DecodeString(encodedSalt)
+	if err != nil {
+		return false
+	}
+	expected, err := hex.DecodeString(encodedMAC)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(k.mac(salt), expected)
+}
+
+func (k *CustomerKey) mac(salt []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(k.Key)
+	return mac.Sum(nil)
+}
+
+// CheckCustomerKey decides whether key may open an object stored with fingerprint
+func CheckCustomerKey(key *CustomerKey, fingerprint string) error {
+	switch {
+	case fingerprint == "" && key == nil:
+		return nil
+	case fingerprint == "":
+		return ErrCustomerKeyNotExpected
+	case key == nil:
+		return ErrCustomerKeyRequired
+	case !key.Matches(fingerprint):
+		return ErrCustomerKeyMismatch
+	}
+	return nil
+}
+
+// NewCustomerWriter is like NewWriter, but wraps the data key with the
+// customer's key instead of the master key
+func NewCustomerWriter(dst io.Writer, key *CustomerKey) (*Writer, error) {
+	return newWriter(dst, key.Key)
+}
+
+// NewCustomerReader opens an object written by NewCustomerWriter
+func NewCustomerReader(src io.ReaderAt, encryptedSize int64, key *CustomerKey) (*Reader, error) {
+	return newReader(src, encryptedSize, key.Key)
+}
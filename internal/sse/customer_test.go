@@ -0,0 +1,135 @@
+package sse
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func customerHeaders(algorithm string, key []byte, keyMD5 string) http.Header {
+	h := http.Header{}
+	if algorithm != "" {
+		h.Set(CustomerHeaderPrefix+"algorithm", algorithm)
+	}
+	if key != nil {
+		h.Set(CustomerHeaderPrefix+"key", base64.StdEncoding.EncodeToString(key))
+	}
+	if keyMD5 != "" {
+		h.Set(CustomerHeaderPrefix+"key-MD5", keyMD5)
+	}
+	return h
+}
+
+func keyMD5(key []byte) string {
+	sum := md5.Sum(key)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestParseCustomerKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, keySize)
+	short := key[:16]
+
+	tests := []struct {
+		name   string
+		header http.Header
+		none   bool   // no key and no error
+		err    string // "" when the key is valid
+	}{
+		{name: "valid", header: customerHeaders(AES256, key, keyMD5(key))},
+		{name: "no headers", header: http.Header{}, none: true},
+		{name: "other algorithm", header: customerHeaders("aws:kms", key, keyMD5(key)), err: "algorithm must be AES256"},
+		{name: "algorithm missing", header: customerHeaders("", key, keyMD5(key)), err: "algorithm must be AES256"},
+		{name: "128-bit key", header: customerHeaders(AES256, short, keyMD5(short)), err: "256-bit key"},
+		{name: "key missing", header: customerHeaders(AES256, nil, keyMD5(key)), err: "256-bit key"},
+		{name: "digest of another key", header: customerHeaders(AES256, key, keyMD5(short)), err: "key-MD5 does not match"},
+		{name: "digest missing", header: customerHeaders(AES256, key, ""), err: "key-MD5 does not match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCustomerKey(tt.header, CustomerHeaderPrefix)
+			switch {
+			case tt.none:
+				if got != nil || err != nil {
+					t.Fatalf("got %v, %v; want nothing", got, err)
+				}
+			case tt.err == "":
+				if err != nil || !bytes.Equal(got.Key, key) {
+					t.Fatalf("got %v, %v; want the key", got, err)
+				}
+			default:
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want an error about %q", err, tt.err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckCustomerKey(t *testing.T) {
+	key := &CustomerKey{Key: bytes.Repeat([]byte{1}, keySize)}
+	other := &CustomerKey{Key: bytes.Repeat([]byte{2}, keySize)}
+	fingerprint, err := key.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := key.Fingerprint()
+	if again == fingerprint {
+		t.Fatal("fingerprints of the same key are not salted")
+	}
+
+	tests := []struct {
+		name        string
+		key         *CustomerKey
+		fingerprint string
+		want        error
+	}{
+		{"plain object", nil, "", nil},
+		{"matching key", key, fingerprint, nil},
+		{"matching key, other salt", key, again, nil},
+		{"key not expected", key, "", ErrCustomerKeyNotExpected},
+		{"key required", nil, fingerprint, ErrCustomerKeyRequired},
+		{"other key", other, fingerprint, ErrCustomerKeyMismatch},
+		{"malformed fingerprint", key, "no-colon", ErrCustomerKeyMismatch},
+		{"fingerprint not hex", key, "zz:zz", ErrCustomerKeyMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckCustomerKey(tt.key, tt.fingerprint); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomerKeyWrapsDataKey(t *testing.T) {
+	key := &CustomerKey{Key: bytes.Repeat([]byte{3}, keySize)}
+	other := &CustomerKey{Key: bytes.Repeat([]byte{4}, keySize)}
+
+	var sealed bytes.Buffer
+	w, err := NewCustomerWriter(&sealed, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "secret data")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewCustomerReader(bytes.NewReader(sealed.Bytes()), int64(sealed.Len()), key)
+	if err != nil {
+		t.Fatalf("NewCustomerReader: %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != "secret data" {
+		t.Fatalf("got %q", got)
+	}
+	if _, err := NewCustomerReader(bytes.NewReader(sealed.Bytes()), int64(sealed.Len()), other); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("other key: got %v, want ErrCorrupted", err)
+	}
+}
@@ -0,0 +1,339 @@
+package sse
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// AES256 is the only x-amz-server-side-encryption value we support
+const AES256 = "AES256"
+
+const (
+	// ChunkSize is the plaintext size sealed per GCM chunk; chunks are what
+	// lets a Range GET decrypt only the part of an object it needs
+	ChunkSize = 64 * 1024
+
+	magic      = "A3SE"
+	version    = 1
+	keySize    = 32
+	nonceSize  = 12
+	tagSize    = 16
+	headerSize = len(magic) + 1 + 4 + nonceSize + keySize + tagSize
+)
+
+var (
+	ErrNoMasterKey = errors.New("server-side encryption is not configured, start the server with --master-key-file")
+	ErrCorrupted   = errors.New("encrypted object is corrupted or was tampered with")
+
+	masterKey []byte
+)
+
+// LoadMasterKey reads the 32-byte master key (raw or hex encoded) from path,
+// generating a new one if the file doesn't exist yet
+func LoadMasterKey(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+			return err
+		}
+		masterKey = key
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(data) != keySize {
+		data, err = hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(data) != keySize {
+			return fmt.Errorf("master key in %s must be 32 raw bytes or 64 hex characters", path)
+		}
+	}
+	masterKey = data
+	return nil
+}
+
+// Enabled reports whether a master key has been loaded
+func Enabled() bool {
+	return masterKey != nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// header is written in front of every encrypted object: the format version,
+// the chunk size and the object's data key wrapped by the master key
+func newHeader(wrapKey, dataKey []byte) ([]byte, error) {
+	gcm, err := newGCM(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, version)
+	header = binary.BigEndian.AppendUint32(header, ChunkSize)
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+	return gcm.Seal(header, nonce, dataKey, []byte(magic)), nil
+}
+
+func parseHeader(wrapKey, header []byte) (dataKey []byte, chunkSize int, err error) {
+	if len(header) != headerSize || string(header[:len(magic)]) != magic || header[len(magic)] != version {
+		return nil, 0, ErrCorrupted
+	}
+	chunkSize = int(binary.BigEndian.Uint32(header[len(magic)+1:]))
+	if chunkSize <= 0 {
+		return nil, 0, ErrCorrupted
+	}
+
+	gcm, err := newGCM(wrapKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	nonceStart := len(magic) + 1 + 4
+	nonce := header[nonceStart : nonceStart+nonceSize]
+	dataKey, err = gcm.Open(nil, nonce, header[nonceStart+nonceSize:], []byte(magic))
+	if err != nil {
+		return nil, 0, ErrCorrupted
+	}
+	return dataKey, chunkSize, nil
+}
+
+// chunkNonce and chunkAAD bind every chunk to its position and mark the last
+// one, so chunks can't be reordered and objects can't be truncated.
+// Counter nonces are safe because each data key encrypts a single object.
+func chunkNonce(index uint64) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[4:], index)
+	return nonce
+}
+
+func chunkAAD(index uint64, final bool) []byte {
+	aad := binary.BigEndian.AppendUint64(nil, index)
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+// Writer encrypts everything written to it into dst
+type Writer struct {
+	dst   io.Writer
+	gcm   cipher.AEAD
+	buf   []byte
+	index uint64
+}
+
+// NewWriter starts an encrypted object with a fresh data key wrapped by the master key
+func NewWriter(dst io.Writer) (*Writer, error) {
+	if !Enabled() {
+		return nil, ErrNoMasterKey
+	}
+	return newWriter(dst, masterKey)
+}
+
+func newWriter(dst io.Writer, wrapKey []byte) (*Writer, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	header, err := newHeader(wrapKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{dst: dst, gcm: gcm, buf: make([]byte, 0, ChunkSize)}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// a full buffer is only sealed once we know more data follows,
+		// because the last chunk has to be marked as final
+		if len(w.buf) == ChunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):ChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk; it does not close the destination
+func (w *Writer) Close() error {
+	return w.seal(true)
+}
+
+func (w *Writer) seal(final bool) error {
+	sealed := w.gcm.Seal(nil, chunkNonce(w.index), w.buf, chunkAAD(w.index, final))
+	if _, err := w.dst.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Reader decrypts an encrypted object and supports seeking, which is what
+// http.ServeContent needs to answer Range requests
+type Reader struct {
+	src       io.ReaderAt
+	gcm       cipher.AEAD
+	chunkSize int
+	chunks    int64
+	size      int64
+	offset    int64
+
+	cached      []byte
+	cachedIndex int64
+}
+
+// NewReader opens an encrypted object of encryptedSize bytes
+func NewReader(src io.ReaderAt, encryptedSize int64) (*Reader, error) {
+	if !Enabled() {
+		return nil, ErrNoMasterKey
+	}
+	return newReader(src, encryptedSize, masterKey)
+}
+
+func newReader(src io.ReaderAt, encryptedSize int64, wrapKey []byte) (*Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := src.ReadAt(header, 0); err != nil {
+		return nil, ErrCorrupted
+	}
+	dataKey, chunkSize, err := parseHeader(wrapKey, header)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	body := encryptedSize - int64(headerSize)
+	full := int64(chunkSize + tagSize)
+	if body < tagSize {
+		return nil, ErrCorrupted
+	}
+	chunks := (body + full - 1) / full
+	last := body - (chunks-1)*full
+	if last < tagSize {
+		return nil, ErrCorrupted
+	}
+
+	reader := &Reader{
+		src:         src,
+		gcm:         gcm,
+		chunkSize:   chunkSize,
+		chunks:      chunks,
+		size:        (chunks-1)*int64(chunkSize) + last - tagSize,
+		cachedIndex: -1,
+	}
+
+	// an empty object is never read, so its only chunk is authenticated up front
+	if reader.size == 0 {
+		if err := reader.load(0); err != nil {
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+// Size is the plaintext size of the object
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.offset / int64(r.chunkSize)
+	if err := r.load(index); err != nil {
+		return 0, err
+	}
+
+	n := copy(p, r.cached[r.offset-index*int64(r.chunkSize):])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *Reader) load(index int64) error {
+	if index == r.cachedIndex {
+		return nil
+	}
+
+	full := int64(r.chunkSize + tagSize)
+	length := full
+	if index == r.chunks-1 {
+		length = r.size - index*int64(r.chunkSize) + tagSize
+	}
+
+	sealed := make([]byte, length)
+	if _, err := r.src.ReadAt(sealed, int64(headerSize)+index*full); err != nil && err != io.EOF {
+		return err
+	}
+
+	final := index == r.chunks-1
+	plain, err := r.gcm.Open(r.cached[:0], chunkNonce(uint64(index)), sealed, chunkAAD(uint64(index), final))
+	if err != nil {
+		r.cachedIndex = -1
+		return ErrCorrupted
+	}
+	r.cached = plain
+	r.cachedIndex = index
+	return nil
+}
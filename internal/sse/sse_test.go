@@ -0,0 +1,164 @@
+package sse
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encrypt(t *testing.T, wrapKey, plain []byte) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	w, err := newWriter(&sealed, wrapKey)
+	if err != nil {
+		t.Fatalf("newWriter: %v", err)
+	}
+	// odd write sizes cross chunk boundaries at every offset
+	for rest := plain; len(rest) > 0; {
+		n := min(len(rest), 1000)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return sealed.Bytes()
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRoundTrip(t *testing.T) {
+	wrapKey := randomBytes(t, keySize)
+	sizes := []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 5}
+
+	for _, size := range sizes {
+		plain := randomBytes(t, size)
+		sealed := encrypt(t, wrapKey, plain)
+
+		r, err := newReader(bytes.NewReader(sealed), int64(len(sealed)), wrapKey)
+		if err != nil {
+			t.Fatalf("size %d: newReader: %v", size, err)
+		}
+		if r.Size() != int64(size) {
+			t.Fatalf("size %d: Size() = %d", size, r.Size())
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: decrypted data differs", size)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	wrapKey := randomBytes(t, keySize)
+	plain := randomBytes(t, 3*ChunkSize+5)
+	sealed := encrypt(t, wrapKey, plain)
+
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		length int
+	}{
+		{"start", 0, io.SeekStart, 10},
+		{"within a chunk", 100, io.SeekStart, 50},
+		{"across chunks", ChunkSize - 3, io.SeekStart, 10},
+		{"whole middle chunk", ChunkSize, io.SeekStart, ChunkSize},
+		{"tail from the end", -5, io.SeekEnd, 5},
+		{"last chunk", 3 * ChunkSize, io.SeekStart, 5},
+	}
+
+	r, err := newReader(bytes.NewReader(sealed), int64(len(sealed)), wrapKey)
+	if err != nil {
+		t.Fatalf("newReader: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := r.Seek(tt.offset, tt.whence)
+			if err != nil {
+				t.Fatalf("Seek: %v", err)
+			}
+			got := make([]byte, tt.length)
+			if _, err := io.ReadFull(r, got); err != nil {
+				t.Fatalf("ReadFull: %v", err)
+			}
+			if !bytes.Equal(got, plain[pos:pos+int64(tt.length)]) {
+				t.Fatalf("data at %d differs", pos)
+			}
+		})
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("negative position was accepted")
+	}
+	if _, err := r.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("read at the end = %d, %v; want 0, EOF", n, err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	wrapKey := randomBytes(t, keySize)
+	plain := randomBytes(t, 2*ChunkSize+100)
+	sealed := encrypt(t, wrapKey, plain)
+	full := ChunkSize + tagSize
+
+	tests := []struct {
+		name   string
+		key    []byte
+		tamper func(b []byte) []byte
+	}{
+		{"wrong key", randomBytes(t, keySize), func(b []byte) []byte { return b }},
+		{"flipped header bit", wrapKey, func(b []byte) []byte { b[headerSize-1] ^= 1; return b }},
+		{"flipped data bit", wrapKey, func(b []byte) []byte { b[headerSize+10] ^= 1; return b }},
+		{"flipped tail bit", wrapKey, func(b []byte) []byte { b[len(b)-1] ^= 1; return b }},
+		{"last chunk dropped", wrapKey, func(b []byte) []byte { return b[:headerSize+2*full] }},
+		{"truncated", wrapKey, func(b []byte) []byte { return b[:len(b)-1] }},
+		{"chunks swapped", wrapKey, func(b []byte) []byte {
+			first := bytes.Clone(b[headerSize : headerSize+full])
+			copy(b[headerSize:], b[headerSize+full:headerSize+2*full])
+			copy(b[headerSize+full:], first)
+			return b
+		}},
+		{"other magic", wrapKey, func(b []byte) []byte { b[0] = 'X'; return b }},
+		{"header only", wrapKey, func(b []byte) []byte { return b[:headerSize] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.tamper(bytes.Clone(sealed))
+			r, err := newReader(bytes.NewReader(b), int64(len(b)), tt.key)
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if !errors.Is(err, ErrCorrupted) {
+				t.Fatalf("got %v, want ErrCorrupted", err)
+			}
+		})
+	}
+}
+
+func TestEmptyObjectIsAuthenticated(t *testing.T) {
+	wrapKey := randomBytes(t, keySize)
+	sealed := encrypt(t, wrapKey, nil)
+	sealed[len(sealed)-1] ^= 1
+	if _, err := newReader(bytes.NewReader(sealed), int64(len(sealed)), wrapKey); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("got %v, want ErrCorrupted", err)
+	}
+}
@@ -0,0 +1,54 @@
+package storagelock
+
+import (
+	"A3S/internal/models"
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+type storageKey struct{}
+
+// lock is the storage lock of one request and whether it holds it right now
+type lock struct {
+	s    *models.Storage
+	held atomic.Bool
+}
+
+// Middleware holds the storage lock while next serves the request, as the
+// metadata slices are read and changed by requests and background workers
+// alike. Handlers give it up with Unlocked while they move object data or
+// wait for events, so a slow transfer does not hold up everybody else.
+func Middleware(s *models.Storage, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := &lock{s: s}
+		s.Lock()
+		l.held.Store(true)
+		defer func() {
+			l.held.Store(false)
+			s.Unlock()
+		}()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), storageKey{}, l)))
+	})
+}
+
+// Unlocked runs fn without the lock the request holds and takes it back
+// afterwards. fn must not touch the storage, and anything looked up in it
+// before may be stale once Unlocked returns.
+func Unlocked(r *http.Request, fn func()) {
+	if l, ok := r.Context().Value(storageKey{}).(*lock); ok {
+		l.held.Store(false)
+		l.s.Unlock()
+		defer func() {
+			l.s.Lock()
+			l.held.Store(true)
+		}()
+	}
+	fn()
+}
+
+// Held reports whether the request holds the storage lock at the moment
+func Held(r *http.Request) bool {
+	l, ok := r.Context().Value(storageKey{}).(*lock)
+	return ok && l.held.Load()
+}
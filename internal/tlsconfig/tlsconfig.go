@@ -0,0 +1,141 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Reloader serves the certificate of a cert/key file pair and can read it
+// again without dropping connections
+type Reloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again; on error the previous certificate stays in use
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// ReloadOnSIGHUP reloads the certificate whenever the process gets SIGHUP,
+// so renewed certificates are picked up without a restart
+func (r *Reloader) ReloadOnSIGHUP() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := r.Reload(); err != nil {
+				log.Printf("Error reloading TLS certificate, keeping the previous one: %v", err)
+				continue
+			}
+			log.Printf("TLS certificate reloaded from %s", r.certFile)
+		}
+	}()
+}
+
+// Config builds the server TLS configuration: from certFile and keyFile, or a
+// throwaway self-signed certificate for development. With clientCA, clients
+// may present a certificate signed by it to authenticate.
+func Config(certFile, keyFile string, selfSigned bool, clientCA string) (*tls.Config, *Reloader, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	var reloader *Reloader
+	switch {
+	case selfSigned:
+		cert, err := SelfSigned()
+		if err != nil {
+			return nil, nil, fmt.Errorf("generating self-signed certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{*cert}
+	case certFile != "":
+		var err error
+		if reloader, err = NewReloader(certFile, keyFile); err != nil {
+			return nil, nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		config.GetCertificate = reloader.GetCertificate
+	default:
+		return nil, nil, errors.New("TLS needs a certificate or a self-signed one")
+	}
+
+	if clientCA != "" {
+		data, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, nil, fmt.Errorf("no PEM certificates in %s", clientCA)
+		}
+		config.ClientCAs = pool
+		// SigV4 keeps working for clients without a certificate
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, reloader, nil
+}
+
+// SelfSigned creates a certificate for localhost and the host name, valid for
+// a year; clients have to be told to trust it
+func SelfSigned() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{"localhost"}
+	if host, err := os.Hostname(); err == nil && host != "localhost" {
+		names = append(names, host)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost", Organization: []string{"A3S development"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     names,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// setting ties a key of the config file to the flag it fills in; the
+// environment variable is the key in upper case with an A3S_ prefix, so
+// listen.port is also A3S_LISTEN_PORT
+type setting struct {
+	Key  string
+	Flag string
+}
+
+// Config lists every key of the --config file, grouped in sections:
+//
+//	{
+//	  "listen":  {"port": 8080, "shutdown_timeout": "30s"},
+//	  "storage": {"dir": "/var/lib/a3s"},
+//	  "logging": {"level": "debug"}
+//	}
+//
+// Flags take precedence over environment variables, which take precedence
+// over the file
+var Config = []setting{
+	{"listen.host", "host"},
+	{"listen.port", "port"},
+	{"listen.website_port", "website-port"},
+	{"listen.website_domain", "website-domain"},
+	{"listen.metrics_port", "metrics-port"},
+	{"listen.shutdown_timeout", "shutdown-timeout"},
+
+	{"tls.cert", "tls-cert"},
+	{"tls.key", "tls-key"},
+	{"tls.self_signed", "tls-self-signed"},
+	{"tls.client_ca", "tls-client-ca"},
+
+	{"storage.dir", "dir"},
+	{"storage.master_key_file", "master-key-file"},
+
+	{"limits.min_free_disk_mb", "min-free-disk-mb"},
+	{"limits.max_object_size_mb", "max-object-size-mb"},
+	{"limits.max_header_kb", "max-header-kb"},
+	{"limits.read_header_timeout", "read-header-timeout"},
+	{"limits.read_timeout", "read-timeout"},
+	{"limits.write_timeout", "write-timeout"},
+	{"limits.idle_timeout", "idle-timeout"},
+	{"limits.min_throughput_kb", "min-throughput-kb"},
+
+	{"auth.access_key", "access-key"},
+	{"auth.secret_key", "secret-key"},
+
+	{"ratelimit.requests", "rate-limit-requests"},
+	{"ratelimit.burst", "rate-limit-burst"},
+	{"ratelimit.bandwidth_kb", "rate-limit-bandwidth-kb"},
+
+	{"replication.dirs", "replication-dirs"},
+	{"replication.endpoints", "replication-endpoints"},
+	{"replication.user", "replication-user"},
+
+	{"notify.endpoints", "notification-endpoints"},
+
+	{"logging.level", "log-level"},
+	{"logging.format", "log-format"},
+	{"logging.access_log", "access-log"},
+
+	{"workers.delivery_attempts", "delivery-attempts"},
+	{"workers.log_flush_interval", "log-flush-interval"},
+}
+
+// origins remembers where each flag got its value, so that a validation
+// error names what the operator has to fix
+var origins = map[string]string{}
+
+// EnvName returns the environment variable of a config key
+func EnvName(key string) string {
+	return "A3S_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// origin names the place the value of a flag came from
+func origin(name string) string {
+	if o, ok := origins[name]; ok {
+		return o
+	}
+	return "--" + name
+}
+
+// loadConfig fills in the flags not given on the command line, first from
+// the config file and then from the environment
+func loadConfig(path string) error {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		for _, s := range Config {
+			value, ok := values[s.Key]
+			if !ok || explicit[s.Flag] {
+				continue
+			}
+			source := fmt.Sprintf("%s in %s", s.Key, path)
+			if err := flag.Set(s.Flag, value); err != nil {
+				return fmt.Errorf("%s: invalid value %q: %v", source, value, err)
+			}
+			origins[s.Flag] = source
+		}
+	}
+
+	for _, s := range Config {
+		value, ok := os.LookupEnv(EnvName(s.Key))
+		if !ok || explicit[s.Flag] {
+			continue
+		}
+		if err := flag.Set(s.Flag, value); err != nil {
+			return fmt.Errorf("%s: invalid value %q: %v", EnvName(s.Key), value, err)
+		}
+		origins[s.Flag] = EnvName(s.Key)
+	}
+	return nil
+}
+
+// readConfigFile flattens the sections of a JSON config file into
+// "section.key" strings in the form the flags parse
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	known := map[string]bool{}
+	for _, s := range Config {
+		known[s.Key] = true
+	}
+
+	values := map[string]string{}
+	var unknown []string
+	for section, body := range sections {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(body, &keys); err != nil || keys == nil {
+			return nil, fmt.Errorf("%s: %s must be a section of keys", path, section)
+		}
+		for name, raw := range keys {
+			key := section + "." + name
+			if !known[key] {
+				unknown = append(unknown, key)
+				continue
+			}
+			raw = bytes.TrimSpace(raw)
+			if len(raw) > 0 && (raw[0] == '{' || raw[0] == '[' || string(raw) == "null") {
+				return nil, fmt.Errorf("%s: %s must be a string, number or boolean", path, key)
+			}
+			var value string
+			if json.Unmarshal(raw, &value) != nil {
+				value = string(raw)
+			}
+			values[key] = value
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%s: unknown key %s", path, strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+// DataPath joins elem onto the storage root set by --dir
+func DataPath(elem ...string) string {
+	return filepath.Join(append([]string{*Dir}, elem...)...)
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freshFlags gives the test a command line of its own over the same flag
+// variables, parsed from args, and puts every flag back to its default
+// afterwards
+func freshFlags(t *testing.T, args ...string) {
+	t.Helper()
+	saved := flag.CommandLine
+	fs := flag.NewFlagSet("s3", flag.ContinueOnError)
+	saved.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	flag.CommandLine = fs
+	origins = map[string]string{}
+	t.Cleanup(func() {
+		fs.VisitAll(func(f *flag.Flag) {
+			f.Value.Set(f.DefValue)
+		})
+		flag.CommandLine = saved
+		origins = map[string]string{}
+	})
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "a3s.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	const file = `{"listen": {"port": 7000, "host": "10.0.0.1", "shutdown_timeout": "5s"}, "tls": {"self_signed": true}}`
+
+	tests := []struct {
+		name   string
+		args   []string
+		env    map[string]string
+		port   int
+		origin string
+	}{
+		{name: "file", port: 7000, origin: "listen.port in "},
+		{name: "environment over file", env: map[string]string{"A3S_LISTEN_PORT": "7100"}, port: 7100, origin: "A3S_LISTEN_PORT"},
+		{name: "flag over both", args: []string{"--port", "7200"}, env: map[string]string{"A3S_LISTEN_PORT": "7100"},
+			port: 7200, origin: "--port"},
+		{name: "flag set to its default", args: []string{"--port", "8080"}, env: map[string]string{"A3S_LISTEN_PORT": "7100"},
+			port: 8080, origin: "--port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freshFlags(t, tt.args...)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			path := writeConfig(t, file)
+			if err := loadConfig(path); err != nil {
+				t.Fatalf("loadConfig: %v", err)
+			}
+			if *Port != tt.port {
+				t.Errorf("port = %d, want %d", *Port, tt.port)
+			}
+			if got := origin("port"); !strings.HasPrefix(got, tt.origin) {
+				t.Errorf("origin = %q, want %q", got, tt.origin)
+			}
+			// the rest of the file applies either way
+			if *Host != "10.0.0.1" || *ShutdownTimeout != 5*time.Second || !*TLSSelfSigned {
+				t.Errorf("file values not applied: host %q, shutdown timeout %s, self-signed %v", *Host, *ShutdownTimeout, *TLSSelfSigned)
+			}
+		})
+	}
+}
+
+func TestConfigWithoutFile(t *testing.T) {
+	freshFlags(t)
+	t.Setenv("A3S_STORAGE_DIR", "/srv/a3s")
+	if err := loadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	if *Dir != "/srv/a3s" || origin("dir") != "A3S_STORAGE_DIR" {
+		t.Fatalf("dir = %q from %s", *Dir, origin("dir"))
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		err  string
+	}{
+		{name: "not JSON", file: `{"listen": `, err: "unexpected end"},
+		{name: "unknown keys", file: `{"listen": {"prot": 1}, "storage": {"dri": "x"}}`, err: "unknown key listen.prot, storage.dri"},
+		{name: "section not an object", file: `{"listen": 8080}`, err: "listen must be a section of keys"},
+		{name: "nested value", file: `{"listen": {"port": [8080]}}`, err: "listen.port must be a string, number or boolean"},
+		{name: "null value", file: `{"listen": {"port": null}}`, err: "listen.port must be a string, number or boolean"},
+		{name: "bad value in file", file: `{"listen": {"port": "eighty"}}`, err: "listen.port in "},
+		{name: "bad duration in file", file: `{"limits": {"read_timeout": 30}}`, err: "limits.read_timeout in "},
+		{name: "bad value in environment", file: `{}`, env: map[string]string{"A3S_LISTEN_PORT": "eighty"}, err: "A3S_LISTEN_PORT: invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freshFlags(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			err := loadConfig(writeConfig(t, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got %v, want an error about %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConfigKeysHaveFlags(t *testing.T) {
+	seen := map[string]bool{}
+	for _, s := range Config {
+		if flag.Lookup(s.Flag) == nil {
+			t.Errorf("%s fills in the unknown flag --%s", s.Key, s.Flag)
+		}
+		if seen[s.Key] {
+			t.Errorf("%s is listed twice", s.Key)
+		}
+		seen[s.Key] = true
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"listen.port":               "A3S_LISTEN_PORT",
+		"storage.master_key_file":   "A3S_STORAGE_MASTER_KEY_FILE",
+		"workers.delivery_attempts": "A3S_WORKERS_DELIVERY_ATTEMPTS",
+	}
+	for key, want := range tests {
+		if got := EnvName(key); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -1,19 +1,67 @@
 package utils
 
 import (
-	"A3S/internal/models"
-	"encoding/xml"
 	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
+	"math"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 var (
+	ConfigFile = flag.String("config", "", "JSON configuration file, also read from A3S_CONFIG")
+
 	Dir  = flag.String("dir", "data", "Path to the directory")
+	Host = flag.String("host", "", "Address to listen on, empty for all interfaces")
 	Port = flag.Int("port", 8080, "Port number")
 	Help = flag.Bool("help", false, "information")
+
+	AccessKey = flag.String("access-key", "", "Root access key for signed requests")
+	SecretKey = flag.String("secret-key", "", "Root secret key for signed requests")
+
+	WebsitePort   = flag.Int("website-port", 0, "Port for static website hosting, 0 disables it")
+	WebsiteDomain = flag.String("website-domain", "", "Domain whose subdomains name website buckets")
+
+	MasterKeyFile = flag.String("master-key-file", "", "Key file for server-side encryption, created if missing")
+
+	MetricsPort = flag.Int("metrics-port", 0, "Port for the Prometheus /metrics endpoint, 0 disables it")
+	MinFreeDisk = flag.Int("min-free-disk-mb", 100, "Free disk space in MiB below which /_readyz fails, 0 disables the check")
+
+	TLSCert       = flag.String("tls-cert", "", "PEM certificate for HTTPS on --port, reloaded on SIGHUP")
+	TLSKey        = flag.String("tls-key", "", "PEM private key of --tls-cert")
+	TLSSelfSigned = flag.Bool("tls-self-signed", false, "Serve HTTPS with a generated self-signed certificate, for development")
+	TLSClientCA   = flag.String("tls-client-ca", "", "PEM CA certificates whose client certificates authenticate as the user in their common name")
+
+	ShutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "Time given to requests in flight and background work on SIGINT or SIGTERM")
+
+	LogLevel  = flag.String("log-level", "info", "Lowest level of the server log: debug, info, warn or error")
+	LogFormat = flag.String("log-format", "json", "Format of the server and access log: json or text")
+	AccessLog = flag.Bool("access-log", true, "Log a line for every request")
+
+	MaxObjectSize     = flag.Int64("max-object-size-mb", 5120, "Largest object a PUT or copy may store, in MiB")
+	MaxHeaderSize     = flag.Int("max-header-kb", 64, "Largest request line and headers, in KiB")
+	ReadHeaderTimeout = flag.Duration("read-header-timeout", 10*time.Second, "Time a client has to send the request headers")
+	ReadTimeout       = flag.Duration("read-timeout", 30*time.Second, "Time a request body may stall without a byte arriving")
+	WriteTimeout      = flag.Duration("write-timeout", 30*time.Second, "Time a single write of a response may block on a client that does not read")
+	IdleTimeout       = flag.Duration("idle-timeout", 2*time.Minute, "Time a keep-alive connection may wait for its next request")
+	MinThroughput     = flag.Int64("min-throughput-kb", 1, "Lowest average rate of a request body in KiB/s once it has run for 10s, 0 disables the check")
+
+	RateLimitRequests  = flag.Float64("rate-limit-requests", 0, "Requests per second each client may make, 0 for no limit")
+	RateLimitBurst     = flag.Int("rate-limit-burst", 0, "Requests a client may make at once, 0 allows one second's worth")
+	RateLimitBandwidth = flag.Int64("rate-limit-bandwidth-kb", 0, "KiB per second each client may upload and download, 0 for no limit")
+
+	ReplicationDirs      = flag.String("replication-dirs", "", "Comma-separated directories replication rules may copy objects into")
+	ReplicationEndpoints = flag.String("replication-endpoints", "", "Comma-separated server URLs replication rules may copy objects to")
+	ReplicationUser      = flag.String("replication-user", "", "User other servers replicate as, whose writes may be marked as replicas besides root's")
+
+	NotificationEndpoints = flag.String("notification-endpoints", "", "Comma-separated webhook URLs notification configurations may deliver to")
+
+	DeliveryAttempts = flag.Int("delivery-attempts", 10, "Tries of a webhook or replication before it is given up")
+	LogFlushInterval = flag.Duration("log-flush-interval", 5*time.Minute, "How long server access logs are buffered before delivery to their target bucket")
 )
 
 func HelpFlag() string {
@@ -21,16 +69,93 @@ func HelpFlag() string {
 Simple Storage Service.
 
 **Usage:**
-	triple-s [-port <N>] [-dir <S>]  
+	triple-s [-config <S>] [-host <S>] [-port <N>] [-dir <S>] [-access-key <S> -secret-key <S>]
+	         [-website-port <N>] [-website-domain <S>] [-master-key-file <S>]
+	         [-metrics-port <N>] [-min-free-disk-mb <N>] [-shutdown-timeout <D>]
+	         [-tls-cert <S> -tls-key <S> | -tls-self-signed] [-tls-client-ca <S>]
+	         [-max-object-size-mb <N>] [-max-header-kb <N>] [-min-throughput-kb <N>]
+	         [-read-header-timeout <D>] [-read-timeout <D>] [-write-timeout <D>] [-idle-timeout <D>]
+	         [-rate-limit-requests <N>] [-rate-limit-burst <N>] [-rate-limit-bandwidth-kb <N>]
+	         [-replication-dirs <S>] [-replication-endpoints <S>] [-replication-user <S>]
+	         [-notification-endpoints <S>]
+	         [-log-level <S>] [-log-format <S>] [-access-log=<B>]
+	         [-delivery-attempts <N>] [-log-flush-interval <D>]
+	triple-s presign -user <S> -bucket <S> -key <S> [-method GET|PUT] [-expires <N>]
+	triple-s user <list|get|create|delete|enable|disable> [-name <S>]
+	triple-s key <list|create> -user <S>
+	triple-s key <enable|disable|rotate|delete> -id <S>
 	triple-s --help
 
 **Options:**
-	--help     Show this screen.
-	--port N   Port number
-	--dir S    Path to the directory
+	--help                      Show this screen.
+	--config S                  JSON configuration file, also read from A3S_CONFIG
+	--host S                    Address to listen on, empty for all interfaces
+	--port N                    Port number
+	--dir S                     Path to the directory
+	--access-key S              Root access key for signed requests
+	--secret-key S              Root secret key for signed requests
+	--website-port N            Port for static website hosting, 0 disables it
+	--website-domain S          Domain whose subdomains name website buckets
+	--master-key-file S         Key file for server-side encryption, created if missing
+	--metrics-port N            Port for the Prometheus /metrics endpoint, 0 disables it
+	--min-free-disk-mb N        Free disk space in MiB below which /_readyz fails, 0 disables the check
+	--shutdown-timeout D        Time given to requests in flight and background work on SIGINT or SIGTERM, like 30s
+	--tls-cert S                PEM certificate for HTTPS on --port, reloaded on SIGHUP
+	--tls-key S                 PEM private key of --tls-cert
+	--tls-self-signed           Serve HTTPS with a generated self-signed certificate, for development
+	--tls-client-ca S           PEM CA certificates whose client certificates authenticate as the user in their common name
+	--max-object-size-mb N      Largest object a PUT or copy may store, in MiB
+	--max-header-kb N           Largest request line and headers, in KiB
+	--read-header-timeout D     Time a client has to send the request headers
+	--read-timeout D            Time a request body may stall without a byte arriving
+	--write-timeout D           Time a single write of a response may block on a client that does not read
+	--idle-timeout D            Time a keep-alive connection may wait for its next request
+	--min-throughput-kb N       Lowest average rate of a request body in KiB/s once it has run for 10s, 0 disables the check
+	--rate-limit-requests N     Requests per second each client may make, 0 for no limit
+	--rate-limit-burst N        Requests a client may make at once, 0 allows one second's worth
+	--rate-limit-bandwidth-kb N KiB per second each client may upload and download, 0 for no limit
+	--replication-dirs S        Comma-separated directories replication rules may copy objects into
+	--replication-endpoints S   Comma-separated server URLs replication rules may copy objects to
+	--replication-user S        User other servers replicate as, whose writes may be marked as replicas besides root's
+	--notification-endpoints S  Comma-separated webhook URLs notification configurations may deliver to
+	--log-level S               Lowest level of the server log: debug, info, warn or error
+	--log-format S              Format of the server and access log: json or text
+	--access-log=B              Log a line for every request, true by default
+	--delivery-attempts N       Tries of a webhook or replication before it is given up
+	--log-flush-interval D      How long server access logs are buffered before delivery to their target bucket
+
+**Configuration:**
+	Every option except --config and --help can also be set in the config file
+	and through an A3S_* environment variable. Flags win over the environment,
+	which wins over the file. The file groups the options in sections:
+
+	{
+	  "listen":      {"host": "", "port": 8080, "website_port": 0, "website_domain": "",
+	                  "metrics_port": 0, "shutdown_timeout": "30s"},
+	  "tls":         {"cert": "", "key": "", "self_signed": false, "client_ca": ""},
+	  "storage":     {"dir": "data", "master_key_file": ""},
+	  "limits":      {"min_free_disk_mb": 100, "max_object_size_mb": 5120, "max_header_kb": 64,
+	                  "read_header_timeout": "10s", "read_timeout": "30s", "write_timeout": "30s",
+	                  "idle_timeout": "2m", "min_throughput_kb": 1},
+	  "auth":        {"access_key": "", "secret_key": ""},
+	  "ratelimit":   {"requests": 0, "burst": 0, "bandwidth_kb": 0},
+	  "replication": {"dirs": "", "endpoints": "", "user": ""},
+	  "notify":      {"endpoints": ""},
+	  "logging":     {"level": "info", "format": "json", "access_log": true},
+	  "workers":     {"delivery_attempts": 10, "log_flush_interval": "5m"}
+	}
+
+	The environment variable of a key is its path in upper case, for example
+	A3S_LISTEN_PORT or A3S_STORAGE_DIR.
 	`
 }
 
+// fail reports a bad setting and exits
+func fail(format string, args ...any) {
+	fmt.Printf(format+"\n", args...)
+	os.Exit(1)
+}
+
 func Checkflag() {
 	err := flag.CommandLine.Parse(os.Args[1:])
 	if err != nil {
@@ -49,32 +174,164 @@ func Checkflag() {
 		os.Exit(0)
 	}
 
-	if _, err := os.Stat(*Dir); os.IsNotExist(err) {
-		data := "data"
-		os.Mkdir(data, 0o777)
+	configFile := *ConfigFile
+	if configFile == "" {
+		configFile = os.Getenv("A3S_CONFIG")
+	}
+	if err := loadConfig(configFile); err != nil {
+		fail("Error: %v", err)
 	}
 
-	if *Port < 1024 || *Port > 49151 {
-		fmt.Println("Port should be 1024-49151")
-		os.Exit(1)
+	if *Dir == "" {
+		fail("%s must not be empty", origin("dir"))
+	}
+	if err := os.MkdirAll(*Dir, 0o755); err != nil {
+		fail("%s: %v", origin("dir"), err)
+	}
+
+	if *Port < 1 || *Port > 65535 {
+		fail("%s should be 1-65535", origin("port"))
+	}
+
+	if *WebsitePort != 0 && (*WebsitePort < 1 || *WebsitePort > 65535 || *WebsitePort == *Port) {
+		fail("%s should be 1-65535 and differ from %s", origin("website-port"), origin("port"))
+	}
+
+	if *MetricsPort != 0 && (*MetricsPort < 1 || *MetricsPort > 65535 || *MetricsPort == *Port || *MetricsPort == *WebsitePort) {
+		fail("%s should be 1-65535 and differ from %s and %s", origin("metrics-port"), origin("port"), origin("website-port"))
+	}
+
+	if (*TLSCert == "") != (*TLSKey == "") {
+		fail("%s and %s must be set together", origin("tls-cert"), origin("tls-key"))
+	}
+
+	if *TLSSelfSigned && *TLSCert != "" {
+		fail("%s cannot be combined with %s", origin("tls-self-signed"), origin("tls-cert"))
+	}
+
+	if *TLSClientCA != "" && !TLSEnabled() {
+		fail("%s needs %s or %s", origin("tls-client-ca"), origin("tls-cert"), origin("tls-self-signed"))
+	}
+
+	if *ShutdownTimeout <= 0 {
+		fail("%s must be positive", origin("shutdown-timeout"))
+	}
+
+	if *MinFreeDisk < 0 {
+		fail("%s must not be negative", origin("min-free-disk-mb"))
+	}
+
+	if (*AccessKey == "") != (*SecretKey == "") {
+		fail("%s and %s must be set together", origin("access-key"), origin("secret-key"))
+	}
+
+	if *MaxObjectSize < 1 {
+		fail("%s must be at least 1", origin("max-object-size-mb"))
+	}
+
+	if *MaxHeaderSize < 1 {
+		fail("%s must be at least 1", origin("max-header-kb"))
+	}
+
+	if *ReadHeaderTimeout <= 0 {
+		fail("%s must be positive", origin("read-header-timeout"))
+	}
+
+	if *ReadTimeout <= 0 {
+		fail("%s must be positive", origin("read-timeout"))
+	}
+
+	if *WriteTimeout <= 0 {
+		fail("%s must be positive", origin("write-timeout"))
+	}
+
+	if *IdleTimeout <= 0 {
+		fail("%s must be positive", origin("idle-timeout"))
+	}
+
+	if *MinThroughput < 0 {
+		fail("%s must not be negative", origin("min-throughput-kb"))
+	}
+
+	if *RateLimitRequests < 0 || math.IsNaN(*RateLimitRequests) || math.IsInf(*RateLimitRequests, 0) {
+		fail("%s must be a number, not negative", origin("rate-limit-requests"))
 	}
-}
 
-func WriteXMLError(w http.ResponseWriter, message string, code int) {
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(code)
+	if *RateLimitBurst < 0 || (*RateLimitBurst > 0 && *RateLimitRequests == 0) {
+		fail("%s must not be negative and needs %s", origin("rate-limit-burst"), origin("rate-limit-requests"))
+	}
 
-	xmlResponse := models.XMLErrorResponse{
-		Message: message,
-		Code:    code,
+	if *RateLimitBandwidth < 0 {
+		fail("%s must not be negative", origin("rate-limit-bandwidth-kb"))
 	}
 
-	xmlData, err := xml.MarshalIndent(xmlResponse, "", "  ")
+	dataDir, err := filepath.Abs(*Dir)
 	if err != nil {
-		log.Printf("Error generating XML response: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		fail("%s: %v", origin("dir"), err)
+	}
+	for _, dir := range SplitList(*ReplicationDirs) {
+		if !filepath.IsAbs(dir) {
+			fail("%s must list absolute paths, not %q", origin("replication-dirs"), dir)
+		}
+		dir = filepath.Clean(dir)
+		if dir == dataDir || strings.HasPrefix(dir, dataDir+string(filepath.Separator)) || strings.HasPrefix(dataDir, dir+string(filepath.Separator)) {
+			fail("%s must not overlap %s: %q", origin("replication-dirs"), origin("dir"), dir)
+		}
+	}
+	for _, endpoint := range SplitList(*ReplicationEndpoints) {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("%s must list http or https URLs, not %q", origin("replication-endpoints"), endpoint)
+		}
+	}
+	for _, endpoint := range SplitList(*NotificationEndpoints) {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("%s must list http or https URLs, not %q", origin("notification-endpoints"), endpoint)
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*LogLevel)); err != nil {
+		fail("%s should be debug, info, warn or error", origin("log-level"))
+	}
+
+	if *LogFormat != "json" && *LogFormat != "text" {
+		fail("%s should be json or text", origin("log-format"))
+	}
+
+	if *DeliveryAttempts < 1 {
+		fail("%s must be at least 1", origin("delivery-attempts"))
 	}
 
-	w.Write(xmlData)
+	if *LogFlushInterval < time.Second {
+		fail("%s must be at least 1s", origin("log-flush-interval"))
+	}
+}
+
+// SplitList splits a comma-separated setting, dropping empty entries
+func SplitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// LogHandler returns the handler chosen by --log-format and --log-level
+func LogHandler() slog.Handler {
+	var level slog.Level
+	level.UnmarshalText([]byte(*LogLevel))
+	options := &slog.HandlerOptions{Level: level}
+	if *LogFormat == "text" {
+		return slog.NewTextHandler(os.Stderr, options)
+	}
+	return slog.NewJSONHandler(os.Stderr, options)
+}
+
+// TLSEnabled reports whether --port serves HTTPS
+func TLSEnabled() bool {
+	return *TLSCert != "" || *TLSSelfSigned
 }
@@ -0,0 +1,218 @@
+package website
+
+import (
+	"A3S/internal/blob"
+	"A3S/internal/models"
+	"A3S/internal/objectkey"
+	"A3S/internal/policy"
+	"A3S/internal/storagelock"
+	"A3S/internal/utils"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// BucketFromHost maps "<bucket>.<website-domain>" to the bucket name; any other
+// host is taken as the bucket name itself, like a CNAME pointing at the server
+func BucketFromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if *utils.WebsiteDomain != "" {
+		if name, found := strings.CutSuffix(host, "."+*utils.WebsiteDomain); found {
+			return name
+		}
+	}
+	return host
+}
+
+// ServeWebsite answers a request on the website listener
+func ServeWebsite(w http.ResponseWriter, r *http.Request, s *models.Storage) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bucket := BucketFromHost(r.Host)
+	if !bucketExists(s, bucket) {
+		http.Error(w, "404 Not Found: NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	config, err := Load(bucket)
+	if err != nil {
+		log.Printf("Error reading website configuration of bucket '%s': %v", bucket, err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if config == nil {
+		http.Error(w, "404 Not Found: NoSuchWebsiteConfiguration", http.StatusNotFound)
+		return
+	}
+
+	if all := config.RedirectAllRequestsTo; all != nil {
+		protocol := all.Protocol
+		if protocol == "" {
+			protocol = requestProtocol(r)
+		}
+		http.Redirect(w, r, protocol+"://"+all.HostName+r.URL.RequestURI(), http.StatusMovedPermanently)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	indexKey := key
+	if key == "" || strings.HasSuffix(key, "/") {
+		indexKey = key + config.IndexDocument.Suffix
+	}
+	// nothing cleans the path on this listener, so "/../other/key" would
+	// otherwise reach into another bucket
+	if _, err := objectkey.Validate(indexKey); err != nil {
+		http.Error(w, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if rule := MatchRule(config, key, 0); rule != nil {
+		redirect(w, r, rule, key)
+		return
+	}
+
+	if !policy.Check(r, s, "s3:GetObject", bucket, indexKey) {
+		serveError(w, r, s, config, bucket, key, http.StatusForbidden)
+		return
+	}
+
+	if object := findObject(s, bucket, indexKey); object != nil {
+		serveObject(w, r, object, indexKey, http.StatusOK)
+		return
+	}
+
+	// "/docs" is a folder when "docs/index.html" exists
+	if indexKey == key && findObject(s, bucket, key+"/"+config.IndexDocument.Suffix) != nil {
+		http.Redirect(w, r, "/"+key+"/", http.StatusFound)
+		return
+	}
+
+	serveError(w, r, s, config, bucket, key, http.StatusNotFound)
+}
+
+// serveError applies error routing rules, then falls back to the ErrorDocument
+func serveError(w http.ResponseWriter, r *http.Request, s *models.Storage, config *models.WebsiteConfiguration, bucket, key string, status int) {
+	if rule := MatchRule(config, key, status); rule != nil {
+		redirect(w, r, rule, key)
+		return
+	}
+
+	if config.ErrorDocument != nil && policy.Check(r, s, "s3:GetObject", bucket, config.ErrorDocument.Key) {
+		if object := findObject(s, bucket, config.ErrorDocument.Key); object != nil {
+			serveObject(w, r, object, config.ErrorDocument.Key, status)
+			return
+		}
+	}
+
+	http.Error(w, strconv.Itoa(status)+" "+http.StatusText(status), status)
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, rule *models.RoutingRule, key string) {
+	rd := rule.Redirect
+
+	protocol := rd.Protocol
+	if protocol == "" {
+		protocol = requestProtocol(r)
+	}
+	host := rd.HostName
+	if host == "" {
+		host = r.Host
+	}
+
+	switch {
+	case rd.ReplaceKeyWith != "":
+		key = rd.ReplaceKeyWith
+	case rd.ReplaceKeyPrefixWith != "":
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = rd.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+
+	code := http.StatusMovedPermanently
+	if rd.HttpRedirectCode != "" {
+		var err error
+		if code, err = strconv.Atoi(rd.HttpRedirectCode); err != nil {
+			log.Printf("Invalid HttpRedirectCode %q: %v", rd.HttpRedirectCode, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.Redirect(w, r, protocol+"://"+host+"/"+key, code)
+}
+
+// serveObject streams an object from disk; error documents keep their error status
+func serveObject(w http.ResponseWriter, r *http.Request, object *models.Object, key string, status int) {
+	body, size, err := blob.Open(object, nil)
+	if err != nil {
+		log.Printf("Error opening object '%s': %v", object.ObjectKey, err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	storagelock.Unlocked(r, func() {
+		if status == http.StatusOK {
+			http.ServeContent(w, r, key, object.LastModified, body)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			io.Copy(w, body)
+		}
+	})
+}
+
+func bucketExists(s *models.Storage, bucket string) bool {
+	for _, b := range s.Buckets {
+		if b.Name == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// findObject only trusts object metadata, so bookkeeping files kept in the
+// bucket directory are never served. Objects encrypted with a customer-provided
+// key can't be decrypted here and are treated as missing.
+func findObject(s *models.Storage, bucket, key string) *models.Object {
+	objectPath := utils.DataPath(bucket, key)
+	for i := range s.Object {
+		if s.Object[i].ObjectKey == objectPath && s.Object[i].CustomerKeyFingerprint == "" {
+			return &s.Object[i]
+		}
+	}
+	return nil
+}
+
+func requestProtocol(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func CreateWebsiteHandler(s *models.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ServeWebsite(w, r, s)
+	}
+}
@@ -0,0 +1,131 @@
+package website
+
+import (
+	"A3S/internal/models"
+	"A3S/internal/utils"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// S3 rejects website configurations larger than this
+const MaxConfigSize = 64 * 1024
+
+func configPath(bucket string) string {
+	return utils.DataPath(bucket, "WebsiteConfiguration.xml")
+}
+
+// Load returns the bucket's website configuration, or nil if hosting is off
+func Load(bucket string) (*models.WebsiteConfiguration, error) {
+	data, err := os.ReadFile(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Save(bucket string, config *models.WebsiteConfiguration) error {
+	data, err := xml.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath(bucket), data, 0o644)
+}
+
+func Delete(bucket string) error {
+	err := os.Remove(configPath(bucket))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Parse decodes a WebsiteConfiguration document and validates it
+func Parse(data []byte) (*models.WebsiteConfiguration, error) {
+	var config models.WebsiteConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("malformed XML: %v", err)
+	}
+
+	if config.RedirectAllRequestsTo != nil {
+		if config.IndexDocument != nil || config.ErrorDocument != nil || len(config.RoutingRules) > 0 {
+			return nil, errors.New("RedirectAllRequestsTo cannot be combined with other website settings")
+		}
+		if config.RedirectAllRequestsTo.HostName == "" {
+			return nil, errors.New("RedirectAllRequestsTo requires a HostName")
+		}
+		if err := validateProtocol(config.RedirectAllRequestsTo.Protocol); err != nil {
+			return nil, err
+		}
+		return &config, nil
+	}
+
+	if config.IndexDocument == nil || config.IndexDocument.Suffix == "" {
+		return nil, errors.New("IndexDocument with a Suffix is required")
+	}
+	if strings.Contains(config.IndexDocument.Suffix, "/") {
+		return nil, errors.New("IndexDocument Suffix must not contain a slash")
+	}
+	if config.ErrorDocument != nil && config.ErrorDocument.Key == "" {
+		return nil, errors.New("ErrorDocument requires a Key")
+	}
+
+	for i, rule := range config.RoutingRules {
+		redirect := rule.Redirect
+		if redirect.ReplaceKeyPrefixWith != "" && redirect.ReplaceKeyWith != "" {
+			return nil, fmt.Errorf("routing rule %d: ReplaceKeyPrefixWith and ReplaceKeyWith are exclusive", i)
+		}
+		if err := validateProtocol(redirect.Protocol); err != nil {
+			return nil, fmt.Errorf("routing rule %d: %v", i, err)
+		}
+		if code := redirect.HttpRedirectCode; code != "" {
+			if n, err := strconv.Atoi(code); err != nil || n < 300 || n > 399 {
+				return nil, fmt.Errorf("routing rule %d: HttpRedirectCode must be a 3XX code", i)
+			}
+		}
+		if rule.Condition != nil {
+			if code := rule.Condition.HttpErrorCodeReturnedEquals; code != "" && (len(code) != 3 || code[0] < '4') {
+				return nil, fmt.Errorf("routing rule %d: HttpErrorCodeReturnedEquals must be a 4XX or 5XX code", i)
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+func validateProtocol(protocol string) error {
+	if protocol != "" && protocol != "http" && protocol != "https" {
+		return fmt.Errorf("invalid Protocol %q", protocol)
+	}
+	return nil
+}
+
+// MatchRule returns the first routing rule that applies to key, given the
+// status code the lookup produced (0 before the object has been looked up)
+func MatchRule(config *models.WebsiteConfiguration, key string, status int) *models.RoutingRule {
+	for i, rule := range config.RoutingRules {
+		condition := rule.Condition
+		if condition == nil {
+			if status == 0 {
+				return &config.RoutingRules[i]
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, condition.KeyPrefixEquals) {
+			continue
+		}
+		if condition.HttpErrorCodeReturnedEquals == "" && status == 0 {
+			return &config.RoutingRules[i]
+		}
+		if condition.HttpErrorCodeReturnedEquals != "" && condition.HttpErrorCodeReturnedEquals == fmt.Sprint(status) {
+			return &config.RoutingRules[i]
+		}
+	}
+	return nil
+}
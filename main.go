@@ -1,36 +1,263 @@
 package main
 
 import (
+	"A3S/internal/accesslog"
+	"A3S/internal/auth"
+	"A3S/internal/blob"
+	"A3S/internal/cli"
+	"A3S/internal/csv"
+	"A3S/internal/events"
+	adminHandl "A3S/internal/handlers/adminHandler"
 	bucketHandl "A3S/internal/handlers/bucketHandler"
 	objectHandl "A3S/internal/handlers/objectHandler"
 	rootHandl "A3S/internal/handlers/rootHandler"
+	"A3S/internal/health"
+	"A3S/internal/iam"
+	"A3S/internal/limits"
+	"A3S/internal/metrics"
 	"A3S/internal/models"
+	"A3S/internal/notify"
+	"A3S/internal/quota"
+	"A3S/internal/ratelimit"
+	"A3S/internal/replication"
+	"A3S/internal/serverlog"
+	"A3S/internal/sse"
+	"A3S/internal/storagelock"
+	"A3S/internal/tlsconfig"
 	"A3S/internal/utils"
+	"A3S/internal/website"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 )
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		cli.Run(os.Args[1:])
+		return
+	}
+
 	utils.Checkflag()
 
+	// the remaining free-form log lines come out in the same format as the access log
+	logger := slog.New(utils.LogHandler())
+	slog.SetDefault(logger)
+	if *utils.AccessLog {
+		accesslog.SetLogger(logger)
+	} else {
+		accesslog.SetLogger(nil)
+	}
+
+	notify.MaxAttempts = *utils.DeliveryAttempts
+	replication.MaxAttempts = *utils.DeliveryAttempts
+	serverlog.FlushInterval = *utils.LogFlushInterval
+
+	limits.ReadTimeout = *utils.ReadTimeout
+	limits.WriteTimeout = *utils.WriteTimeout
+	limits.MinThroughput = *utils.MinThroughput << 10
+
+	ratelimit.Client = models.RateLimit{
+		RequestsPerSecond: *utils.RateLimitRequests,
+		Burst:             *utils.RateLimitBurst,
+		BytesPerSecond:    *utils.RateLimitBandwidth << 10,
+	}
+
+	if *utils.MasterKeyFile != "" {
+		if err := sse.LoadMasterKey(*utils.MasterKeyFile); err != nil {
+			log.Fatalf("Error loading master key: %v", err)
+		}
+	}
+
 	system := &models.Storage{}
+	if err := iam.Load(system); err != nil {
+		log.Fatalf("Error %v", err)
+	}
+
+	// bucket owners must survive restarts for access checks to hold
+	buckets, err := csv.CSVLoadBuckets()
+	if err != nil {
+		log.Fatalf("Error loading bucket metadata: %v", err)
+	}
+	system.Buckets = buckets
+
+	for _, b := range buckets {
+		objects, err := csv.CSVLoadObjects(b.Name)
+		if err != nil {
+			log.Fatalf("Error loading object metadata of bucket '%s': %v", b.Name, err)
+		}
+		system.Object = append(system.Object, objects...)
+	}
+
+	for _, b := range quota.Recount(system) {
+		csv.CSVUpdateBucketMetaData(b)
+	}
+
+	// chunk reference counts are derived from the object metadata
+	if err := blob.Load(system.Object); err != nil {
+		log.Fatalf("Error loading chunk store: %v", err)
+	}
+
+	// webhook deliveries queued before a restart are picked up again
+	events.Listen(notify.Emit)
+	notify.Start()
+
+	// copies to other servers or directories resume where they stopped
+	replication.Start(system)
+
+	// buckets with logging enabled get their access log as objects
+	serverlog.Start(system, objectHandl.StoreObject)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", rootHandl.CreateRootHandler(system))
 	mux.HandleFunc("/{bucket}", bucketHandl.CreateBucketHandler(system))
-	mux.HandleFunc("/{bucket}/", bucketHandl.CreateBucketHandler(system))
-	mux.HandleFunc("/{bucket}/{object}", objectHandl.CreateObjectHandler(system))
-	mux.HandleFunc("/{bucket}/{object}/", objectHandl.CreateObjectHandler(system))
+	mux.HandleFunc("/{bucket}/{object...}", objectHandl.CreateObjectHandler(system))
+	mux.HandleFunc("/_admin/presign", adminHandl.CreatePresignHandler(system))
+	mux.HandleFunc("/_admin/users", adminHandl.CreateUsersHandler(system))
+	mux.HandleFunc("/_admin/users/{user}", adminHandl.CreateUsersHandler(system))
+	mux.HandleFunc("/_admin/users/{user}/{action}", adminHandl.CreateUserActionHandler(system))
+	mux.HandleFunc("/_admin/keys/{key}", adminHandl.CreateKeyActionHandler(system))
+	mux.HandleFunc("/_admin/keys/{key}/{action}", adminHandl.CreateKeyActionHandler(system))
+	mux.HandleFunc("/_admin/compression", adminHandl.CreateCompressionStatsHandler(system))
+	mux.HandleFunc("/_admin/quotas", adminHandl.CreateQuotasHandler(system))
+	mux.HandleFunc("/_admin/quotas/{bucket}", adminHandl.CreateQuotasHandler(system))
+	mux.HandleFunc("/_admin/ratelimits", adminHandl.CreateRateLimitsHandler(system))
+	mux.HandleFunc("/_admin/ratelimits/{bucket}", adminHandl.CreateRateLimitsHandler(system))
 
-	s := http.Server{
-		Addr:    ":" + strconv.Itoa(*utils.Port),
-		Handler: mux,
+	// probes start with "_", which no bucket name can
+	readyz := health.Readyz(*utils.Dir, uint64(*utils.MinFreeDisk)<<20)
+	mux.HandleFunc("/_healthz", health.Healthz)
+	mux.HandleFunc("/_readyz", readyz)
+	mux.HandleFunc("/_version", health.VersionHandler)
+
+	health.SetReady(true)
+
+	s := newServer(*utils.Port, metrics.Middleware(accesslog.Middleware(storagelock.Middleware(system, auth.Middleware(system, ratelimit.Middleware(system, mux))))))
+	// event streams never end on their own
+	s.RegisterOnShutdown(events.CloseAll)
+	servers := []*http.Server{s}
+
+	// HTTP/2 is negotiated automatically on TLS connections
+	if utils.TLSEnabled() {
+		tlsConfig, reloader, err := tlsconfig.Config(*utils.TLSCert, *utils.TLSKey, *utils.TLSSelfSigned, *utils.TLSClientCA)
+		if err != nil {
+			log.Fatalf("Error %v", err)
+		}
+		if reloader != nil {
+			reloader.ReloadOnSIGHUP()
+		}
+		s.TLSConfig = tlsConfig
+		fmt.Printf("Server is running with TLS on port: %d\n", *utils.Port)
+	} else {
+		fmt.Printf("Server is running on port: %d\n", *utils.Port)
 	}
-	fmt.Printf("Server is running on port: %d\n", *utils.Port)
 
-	if err := s.ListenAndServe(); err != nil {
+	if *utils.WebsitePort != 0 {
+		servers = append(servers, newServer(*utils.WebsitePort, accesslog.Middleware(storagelock.Middleware(system, website.CreateWebsiteHandler(system)))))
+		fmt.Printf("Website endpoint is running on port: %d\n", *utils.WebsitePort)
+	}
+
+	// a port of its own keeps /metrics apart from a bucket named "metrics"
+	// and reachable by scrapers that do not sign requests
+	if *utils.MetricsPort != 0 {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler(system))
+		metricsMux.HandleFunc("/healthz", health.Healthz)
+		metricsMux.HandleFunc("/readyz", readyz)
+		metricsMux.HandleFunc("/version", health.VersionHandler)
+		servers = append(servers, newServer(*utils.MetricsPort, metricsMux))
+		fmt.Printf("Metrics endpoint is running on port: %d\n", *utils.MetricsPort)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			var err error
+			if server.TLSConfig != nil {
+				err = server.ListenAndServeTLS("", "")
+			} else {
+				err = server.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errs:
 		log.Fatalf("Error %v", err)
+	case <-ctx.Done():
+	}
+	// a second signal kills the process right away
+	stop()
+
+	if err := shutdown(servers); err != nil {
+		log.Printf("Shutdown did not finish in time: %v", err)
+		os.Exit(1)
+	}
+	log.Printf("Server stopped")
+}
+
+// newServer listens on port with the header limit and timeouts from the
+// flags; request bodies and responses get deadlines of their own from
+// limits.Middleware, so there is no ReadTimeout or WriteTimeout cutting off
+// large transfers
+func newServer(port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              net.JoinHostPort(*utils.Host, strconv.Itoa(port)),
+		Handler:           limits.Middleware(handler),
+		MaxHeaderBytes:    *utils.MaxHeaderSize << 10,
+		ReadHeaderTimeout: *utils.ReadHeaderTimeout,
+		IdleTimeout:       *utils.IdleTimeout,
 	}
 }
+
+// shutdown stops taking requests, lets the ones in flight finish and then
+// stops the background workers, each step within --shutdown-timeout. The
+// workers are stopped even when requests did not finish in time.
+func shutdown(servers []*http.Server) error {
+	log.Printf("Shutting down, waiting up to %s for requests in flight", *utils.ShutdownTimeout)
+	health.SetReady(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *utils.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var failed []error
+	for err := range errs {
+		failed = append(failed, err)
+	}
+
+	// the workers write metadata too, so they stop only after the handlers.
+	// They get a deadline of their own, so queues and buffered log records
+	// are still flushed when requests ran past the first one.
+	workerCtx, workerCancel := context.WithTimeout(context.Background(), *utils.ShutdownTimeout)
+	defer workerCancel()
+	failed = append(failed, notify.Stop(workerCtx), replication.Stop(workerCtx), serverlog.Stop(workerCtx))
+	return errors.Join(failed...)
+}